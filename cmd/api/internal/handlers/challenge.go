@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/nats-io/nats.go"
+	"github.com/pkg/errors"
+	"github.com/schafer14/chess-serve/internal/challenge"
+	"github.com/volatiletech/authboss"
+)
+
+type ChallengeHandler struct {
+	challenges *challenge.Challenges
+	nc         *nats.Conn
+	ab         *authboss.Authboss
+}
+
+// NewChallenge is a game the player asks a registered user to play. Color is
+// the side the challenger plays: white, black or random.
+type NewChallenge struct {
+	UserId      string       `json:"userId" validate:"required"`
+	TimeControl *TimeControl `json:"timeControl"`
+	Variant     string       `json:"variant" validate:"omitempty,oneof=standard chess960 kingOfTheHill threeCheck racingKings crazyhouse"`
+	Color       string       `json:"color" validate:"omitempty,oneof=white black random"`
+	Rated       bool         `json:"rated"`
+}
+
+// Pending lists the open challenges the player sent or was sent.
+func (c ChallengeHandler) Pending(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	p := getPlayer(w, r, c.ab)
+
+	pending, err := c.challenges.Pending(ctx, p.Id)
+	if err != nil {
+		RespondError(ctx, w, errors.Wrap(err, "listing challenges"))
+		return
+	}
+
+	Respond(ctx, w, pending, http.StatusOK)
+	return
+}
+
+// Create challenges a user. The user is told about the challenge by a
+// challenge message on their challenge websocket.
+func (c ChallengeHandler) Create(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var n NewChallenge
+	if err := Decode(r, &n); err != nil {
+		RespondError(ctx, w, err)
+		return
+	}
+
+	opts, err := NewGame{TimeControl: n.TimeControl, Variant: n.Variant, Rated: n.Rated}.options()
+	if err != nil {
+		RespondError(ctx, w, err)
+		return
+	}
+
+	// Only registered users can be challenged.
+	user, err := c.ab.Config.Storage.Server.Load(ctx, n.UserId)
+	if err == authboss.ErrUserNotFound {
+		RespondError(ctx, w, Error{fmt.Errorf("unable to validate request"), http.StatusUnprocessableEntity, []FieldError{
+			{Field: "userId", Error: err.Error()},
+		}})
+		return
+	}
+	if err != nil {
+		RespondError(ctx, w, errors.Wrap(err, "loading user"))
+		return
+	}
+
+	ch := challenge.Challenge{
+		UserId:      n.UserId,
+		TimeControl: opts.TimeControl,
+		Variant:     opts.Variant,
+		Color:       n.Color,
+		Rated:       opts.Rated,
+	}
+	if u, ok := user.(authboss.ArbitraryUser); ok {
+		ch.UserName = u.GetArbitrary()["name"]
+	}
+
+	p := getPlayer(w, r, c.ab)
+
+	ch, err = c.challenges.Send(ctx, ch, p, time.Now())
+	if err != nil {
+		RespondError(ctx, w, challengeError(err))
+		return
+	}
+
+	Respond(ctx, w, ch, http.StatusOK)
+	return
+}
+
+// Accept starts the game of a challenge. The challenger finds out about
+// the game from an accepted message.
+func (c ChallengeHandler) Accept(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	challengeId := chi.URLParam(r, "challengeId")
+
+	p := getPlayer(w, r, c.ab)
+
+	game, err := c.challenges.Accept(ctx, challengeId, p, time.Now())
+	if err != nil {
+		RespondError(ctx, w, challengeError(err))
+		return
+	}
+
+	Respond(ctx, w, game, http.StatusOK)
+	return
+}
+
+// Decline turns down a challenge.
+func (c ChallengeHandler) Decline(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	challengeId := chi.URLParam(r, "challengeId")
+
+	p := getPlayer(w, r, c.ab)
+
+	if err := c.challenges.Decline(ctx, challengeId, p.Id); err != nil {
+		RespondError(ctx, w, challengeError(err))
+		return
+	}
+
+	Respond(ctx, w, nil, http.StatusNoContent)
+	return
+}
+
+// Cancel takes back a challenge.
+func (c ChallengeHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	challengeId := chi.URLParam(r, "challengeId")
+
+	p := getPlayer(w, r, c.ab)
+
+	if err := c.challenges.Cancel(ctx, challengeId, p.Id); err != nil {
+		RespondError(ctx, w, challengeError(err))
+		return
+	}
+
+	Respond(ctx, w, nil, http.StatusNoContent)
+	return
+}
+
+// Follow uses websockets to tell the player about challenges they sent or
+// were sent in real time.
+func (c ChallengeHandler) Follow(w http.ResponseWriter, r *http.Request) {
+	p := getPlayer(w, r, c.ab)
+
+	upgrader.CheckOrigin = func(r *http.Request) bool { return true }
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	// A connection can only have one writer at a time.
+	var mu sync.Mutex
+	write := func(msg WsMessage) {
+		mu.Lock()
+		defer mu.Unlock()
+		conn.WriteJSON(msg)
+	}
+
+	sub, err := c.nc.Subscribe(challenge.Subject(p.Id)+".*", func(m *nats.Msg) {
+		parts := strings.Split(m.Subject, ".")
+		write(WsMessage{parts[len(parts)-1], string(m.Data)})
+	})
+	if err != nil {
+		log.Println(err)
+		conn.Close()
+		return
+	}
+
+	go func() {
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				sub.Unsubscribe()
+				conn.Close()
+				return
+			}
+		}
+	}()
+}
+
+// challengeError converts errors from challenges into errors for the client.
+func challengeError(err error) error {
+	switch errors.Cause(err) {
+	case challenge.ErrChallengeNotFound:
+		return Error{err, http.StatusNotFound, nil}
+	case challenge.ErrNotChallenged, challenge.ErrNotChallenger:
+		return Error{err, http.StatusForbidden, nil}
+	case challenge.ErrSelfChallenge:
+		return Error{fmt.Errorf("unable to validate request"), http.StatusUnprocessableEntity, []FieldError{
+			{Field: "userId", Error: err.Error()},
+		}}
+	}
+
+	return errors.Wrap(err, "answering challenge")
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/schafer14/chess-serve/internal/chess"
+)
+
+// Events lists everything that happened in a game. The since query parameter
+// skips the first events, so a client that has seen N events can ask for the
+// ones after them with since=N.
+func (g GameHandler) Events(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	gameId := chi.URLParam(r, "gameId")
+
+	since := 0
+	if s := r.URL.Query().Get("since"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			RespondError(ctx, w, Error{fmt.Errorf("unable to validate request"), http.StatusUnprocessableEntity, []FieldError{
+				{Field: "since", Error: "since must be a number of events"},
+			}})
+			return
+		}
+		since = n
+	}
+
+	p := getPlayer(w, r, g.ab)
+
+	game, err := g.findVisible(r, gameId, p)
+	if err != nil {
+		RespondError(ctx, w, err)
+		return
+	}
+
+	Respond(ctx, w, game.Events(since), http.StatusOK)
+	return
+}
+
+// publishEvents tells followers of a game about the events after the first
+// seen events. Every event is published on the event subject. The events
+// the web client shows are also published on their own subjects.
+func (g GameHandler) publishEvents(game chess.Game, seen int, now time.Time) {
+	gameId := game.ID()
+
+	for _, e := range game.Events(seen) {
+		g.publish(gameId, "event", e)
+
+		switch e.Type {
+		case chess.EventJoined:
+			g.publish(gameId, "join", map[string]string{"color": e.Color, "name": e.Name})
+		case chess.EventMoved:
+			position := Position{Fen: game.Fen(), San: e.San, Clock: game.Clock(now), Checks: game.Checks(), Pockets: game.Pockets()}
+			g.publish(gameId, "fen", position)
+		case chess.EventDrawOffered:
+			g.publish(gameId, "offer-draw", map[string]string{"name": e.Name})
+		case chess.EventDrawDeclined:
+			g.publish(gameId, "decline-draw", map[string]string{"name": e.Name})
+		case chess.EventTakebackProposed:
+			g.publish(gameId, "offer-takeback", map[string]string{"name": e.Name})
+		case chess.EventTakebackDeclined:
+			g.publish(gameId, "decline-takeback", map[string]string{"name": e.Name})
+		case chess.EventTakenBack:
+			position := Position{Fen: game.Fen(), Clock: game.Clock(now), Checks: game.Checks(), Pockets: game.Pockets()}
+			g.publish(gameId, "fen", position)
+		case chess.EventRematchOffered:
+			g.publish(gameId, "offer-rematch", map[string]string{"name": e.Name})
+		case chess.EventRematchAccepted:
+			g.publish(gameId, "rematch", map[string]string{"gameId": e.GameId})
+		}
+
+		if e.Outcome != nil {
+			g.publish(gameId, "end", e.Outcome)
+		}
+	}
+}
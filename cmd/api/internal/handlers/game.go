@@ -1,9 +1,12 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -14,22 +17,31 @@ import (
 	"github.com/nats-io/nats.go"
 	"github.com/pkg/errors"
 	"github.com/schafer14/chess-serve/internal/chess"
+	"github.com/schafer14/chess-serve/internal/invite"
 	"github.com/volatiletech/authboss"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type GameHandler struct {
-	coll *mongo.Collection
-	nc   *nats.Conn
-	ab   *authboss.Authboss
+	games   chess.GameStore
+	nc      *nats.Conn
+	ab      *authboss.Authboss
+	invites *invite.Signer
+
+	// ratedTakebacks allows takebacks in rated games.
+	ratedTakebacks bool
 }
 
+// errRatedTakeback is returned for takebacks in rated games when they are
+// not allowed.
+var errRatedTakeback = errors.New("takebacks are not allowed in rated games")
+
 var store = sessions.NewCookieStore([]byte("aasdf;oi4jra"))
 
 func getPlayer(w http.ResponseWriter, r *http.Request, ab *authboss.Authboss) chess.Player {
 	var name, id string
 	name = "Guest"
+	rating := chess.DefaultRating
 
 	t, err := ab.CurrentUser(r)
 	if err != nil {
@@ -53,9 +65,63 @@ func getPlayer(w http.ResponseWriter, r *http.Request, ab *authboss.Authboss) ch
 		if u.GetArbitrary()["name"] != "" {
 			name = u.GetArbitrary()["name"]
 		}
+		if n, err := strconv.Atoi(u.GetArbitrary()["rating"]); err == nil {
+			rating = n
+		}
 	}
 
-	return chess.Player{id, name}
+	return chess.Player{Id: id, Name: name, Rating: rating}
+}
+
+// TimeControl is the time each player gets in seconds. Either an increment
+// or a delay can be given but not both.
+type TimeControl struct {
+	Base      int `json:"base" validate:"min=1"`
+	Increment int `json:"increment" validate:"min=0"`
+	Delay     int `json:"delay" validate:"min=0"`
+}
+
+// NewGame are the settings a game can be created with. Color is the side
+// the creator plays: white, black or random.
+type NewGame struct {
+	TimeControl *TimeControl `json:"timeControl"`
+	Fen         string       `json:"fen"`
+	Variant     string       `json:"variant" validate:"omitempty,oneof=standard chess960 kingOfTheHill threeCheck racingKings crazyhouse"`
+	Position    *int         `json:"position" validate:"omitempty,min=0,max=959"`
+	Rated       bool         `json:"rated"`
+	Color       string       `json:"color" validate:"omitempty,oneof=white black random"`
+	Visibility  string       `json:"visibility" validate:"omitempty,oneof=public unlisted private"`
+}
+
+// options converts a new game request into options for the chess package.
+func (n NewGame) options() (chess.Options, error) {
+	var opts chess.Options
+
+	if tc := n.TimeControl; tc != nil {
+		if tc.Increment > 0 && tc.Delay > 0 {
+			return opts, Error{fmt.Errorf("unable to validate request"), http.StatusUnprocessableEntity, []FieldError{
+				{Field: "timeControl.delay", Error: "delay can not be used with an increment"},
+			}}
+		}
+		opts.TimeControl = &chess.TimeControl{Base: tc.Base, Increment: tc.Increment, Delay: tc.Delay}
+	}
+	opts.Fen = n.Fen
+	opts.Variant = n.Variant
+	opts.Rated = n.Rated
+	opts.Color = n.Color
+	opts.Visibility = n.Visibility
+
+	// Position numbers only pick Chess960 start positions.
+	if n.Position != nil {
+		if n.Variant != chess.VariantChess960 || n.Fen != "" {
+			return opts, Error{fmt.Errorf("unable to validate request"), http.StatusUnprocessableEntity, []FieldError{
+				{Field: "position", Error: "position can only be used for chess960 games without a fen"},
+			}}
+		}
+		opts.Chess960Position = n.Position
+	}
+
+	return opts, nil
 }
 
 // Create starts a game. The game will originally be in a initalizing phase
@@ -64,11 +130,29 @@ func (g GameHandler) Create(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	now := time.Now()
 
+	var n NewGame
+	if r.ContentLength != 0 {
+		if err := Decode(r, &n); err != nil {
+			RespondError(ctx, w, err)
+			return
+		}
+	}
+
+	opts, err := n.options()
+	if err != nil {
+		RespondError(ctx, w, err)
+		return
+	}
+
 	p := getPlayer(w, r, g.ab)
 
-	game := chess.NewGame(primitive.NewObjectID(), now, p)
+	game, err := chess.NewGame(primitive.NewObjectID(), now, p, opts)
+	if err != nil {
+		RespondError(ctx, w, gameError(err))
+		return
+	}
 
-	err := game.Save(ctx, g.coll)
+	err = g.games.Create(ctx, game)
 	if err != nil {
 		RespondError(ctx, w, errors.Wrap(err, "creating game"))
 		return
@@ -86,9 +170,9 @@ func (g GameHandler) Find(w http.ResponseWriter, r *http.Request) {
 
 	p := getPlayer(w, r, g.ab)
 
-	game, err := chess.FindById(ctx, g.coll, gameId, p)
+	game, err := g.findVisible(r, gameId, p)
 	if err != nil {
-		RespondError(ctx, w, errors.Wrap(err, "creating game"))
+		RespondError(ctx, w, err)
 		return
 	}
 
@@ -104,9 +188,9 @@ func (g GameHandler) Fen(w http.ResponseWriter, r *http.Request) {
 
 	p := getPlayer(w, r, g.ab)
 
-	game, err := chess.FindById(ctx, g.coll, gameId, p)
+	game, err := g.findVisible(r, gameId, p)
 	if err != nil {
-		RespondError(ctx, w, errors.Wrap(err, "creating game"))
+		RespondError(ctx, w, err)
 		return
 	}
 
@@ -122,26 +206,26 @@ func (g GameHandler) Join(w http.ResponseWriter, r *http.Request) {
 
 	p := getPlayer(w, r, g.ab)
 
-	game, err := chess.FindById(ctx, g.coll, gameId, p)
+	// Joining a private game needs an invite.
+	game, err := g.findVisible(r, gameId, p)
 	if err != nil {
-		RespondError(ctx, w, errors.Wrap(err, "creating game"))
+		RespondError(ctx, w, err)
 		return
 	}
 
-	game.Join(p)
-	if err != nil {
-		RespondError(ctx, w, errors.Wrap(err, "joining game"))
+	seen := len(game.Events(0))
+	if err := game.Join(p, time.Now()); err != nil {
+		RespondError(ctx, w, gameError(err))
 		return
 	}
-	err = game.Save(ctx, g.coll)
+
+	err = g.games.Update(ctx, game)
 	if err != nil {
-		RespondError(ctx, w, errors.Wrap(err, "saving game"))
+		RespondError(ctx, w, saveError(err))
 		return
 	}
 
-	sub := fmt.Sprintf("game.%v.join", gameId)
-	msg := fmt.Sprintf("{\"color\": \"black\", \"name\": \"%s\"}", p.Name)
-	g.nc.Publish(sub, []byte(msg))
+	g.publishEvents(game, seen, time.Now())
 
 	Respond(ctx, w, game, http.StatusOK)
 	return
@@ -161,6 +245,13 @@ type WsMessage struct {
 func (g GameHandler) Follow(w http.ResponseWriter, r *http.Request) {
 	gameId := chi.URLParam(r, "gameId")
 
+	p := getPlayer(w, r, g.ab)
+
+	if _, err := g.findVisible(r, gameId, p); err != nil {
+		RespondError(r.Context(), w, err)
+		return
+	}
+
 	upgrader.CheckOrigin = func(r *http.Request) bool { return true }
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
@@ -168,16 +259,80 @@ func (g GameHandler) Follow(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	g.nc.Subscribe(fmt.Sprintf("game.%v.*", gameId), func(m *nats.Msg) {
+	sub, err := g.nc.Subscribe(fmt.Sprintf("game.%v.*", gameId), func(m *nats.Msg) {
 		parts := strings.Split(m.Subject, ".")
 		sub := parts[len(parts)-1]
 		msg := WsMessage{sub, string(m.Data)}
 		conn.WriteJSON(msg)
 	})
+	if err != nil {
+		log.Println(err)
+		conn.Close()
+		return
+	}
+
+	go g.watch(conn, sub, gameId, p)
+}
+
+// seenInterval is how often a player following a game is marked as present.
+const seenInterval = 5 * time.Second
+
+// watch keeps track of a player following a game until the connection is
+// closed so that players who leave a game can be found.
+func (g GameHandler) watch(conn *websocket.Conn, sub *nats.Subscription, gameId string, p chess.Player) {
+	closed := make(chan struct{})
+	go func() {
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				close(closed)
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(seenInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := g.games.MarkSeen(context.Background(), gameId, p.Id, time.Now()); err != nil {
+			log.Println(err)
+		}
+
+		select {
+		case <-closed:
+			sub.Unsubscribe()
+			conn.Close()
+			return
+		case <-ticker.C:
+		}
+	}
 }
 
+// Move is a move in UCI (e2e4), long algebraic (e2-e4) or standard
+// algebraic (e4) notation. Promotions add the new piece to the move like
+// e7e8q, e7-e8=N or e8=R. Crazyhouse drops are written like N@f3.
+//
+// Ply is the number of half moves the player believes have been played
+// before their move. When it is given the move is rejected with a conflict
+// if the game is at a different ply.
+//
+// MoveId makes retrying the move safe. A move with an id that was already
+// played by the same player succeeds again without being played twice, and
+// reusing it for a different move is a conflict. The id can also be sent in
+// the Idempotency-Key header.
 type Move struct {
-	Move string `json:"move"`
+	Move   string `json:"move"`
+	Ply    *int   `json:"ply" validate:"omitempty,min=0"`
+	MoveId string `json:"moveId" validate:"omitempty,max=128"`
+}
+
+// Position is sent to followers of a game after every move.
+type Position struct {
+	Fen     string         `json:"fen"`
+	San     string         `json:"san,omitempty"`
+	Clock   *chess.Clock   `json:"clock,omitempty"`
+	Checks  *chess.Checks  `json:"checks,omitempty"`
+	Pockets *chess.Pockets `json:"pockets,omitempty"`
 }
 
 // Move applies a move to the game.
@@ -192,27 +347,310 @@ func (g GameHandler) Move(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	key, err := moveKey(r, m)
+	if err != nil {
+		RespondError(ctx, w, err)
+		return
+	}
+
 	p := getPlayer(w, r, g.ab)
 
-	game, err := chess.FindById(ctx, g.coll, gameId, p)
+	game, err := chess.FindById(ctx, g.games, gameId, p)
 	if err != nil {
 		RespondError(ctx, w, errors.Wrap(err, "creating game"))
 		return
 	}
 
-	err = game.Move(m.Move, p.Id)
+	// A retry of a move that was played gets the same response again. This
+	// is checked first as the game has moved on to the next ply since.
+	if key != "" {
+		played, err := game.Played(p.Id, key, m.Move)
+		if err != nil {
+			RespondError(ctx, w, gameError(err))
+			return
+		}
+		if played {
+			Respond(ctx, w, nil, http.StatusNoContent)
+			return
+		}
+	}
+
+	if m.Ply != nil && *m.Ply != game.Ply() {
+		err := errors.Errorf("the game is at ply %d, not %d", game.Ply(), *m.Ply)
+		RespondError(ctx, w, Error{err, http.StatusConflict, nil})
+		return
+	}
+
+	now := time.Now()
+	seen := len(game.Events(0))
+
+	err = game.Move(m.Move, p.Id, now)
+	if err == chess.ErrFlagFall {
+		// The game has ended on time so it still needs to be saved.
+		if err := g.games.Update(ctx, game); err != nil {
+			RespondError(ctx, w, saveError(err))
+			return
+		}
+		g.publishEvents(game, seen, now)
+	}
 	if err != nil {
-		http.Error(w, http.StatusText(422), 422)
+		RespondError(ctx, w, gameError(err))
+		return
+	}
+	if key != "" {
+		game.RememberMove(p.Id, key, m.Move)
 	}
 
-	game.Save(ctx, g.coll)
+	err = g.games.Update(ctx, game)
+	if errors.Cause(err) == chess.ErrConflict && key != "" {
+		// The retry may have lost against the original request saving the
+		// same move.
+		if saved, err := chess.FindById(ctx, g.games, gameId, p); err == nil {
+			if played, _ := saved.Played(p.Id, key, m.Move); played {
+				Respond(ctx, w, nil, http.StatusNoContent)
+				return
+			}
+		}
+	}
 	if err != nil {
-		RespondError(ctx, w, errors.Wrap(err, "saving game"))
+		RespondError(ctx, w, saveError(err))
 		return
 	}
 
-	g.nc.Publish(fmt.Sprintf("game.%v.fen", gameId), []byte(game.Fen()))
+	g.publishEvents(game, seen, now)
 
 	Respond(ctx, w, nil, http.StatusNoContent)
 	return
 }
+
+// moveKey is the idempotency key of a move from either the Idempotency-Key
+// header or the moveId of the move.
+func moveKey(r *http.Request, m Move) (string, error) {
+	key := r.Header.Get("Idempotency-Key")
+	if key != "" && m.MoveId != "" && key != m.MoveId {
+		return "", Error{fmt.Errorf("unable to validate request"), http.StatusUnprocessableEntity, []FieldError{
+			{Field: "moveId", Error: "moveId must match the Idempotency-Key header"},
+		}}
+	}
+	if key == "" {
+		key = m.MoveId
+	}
+
+	return key, nil
+}
+
+// ClaimDraw ends the game in a draw when the position allows one to be
+// claimed.
+func (g GameHandler) ClaimDraw(w http.ResponseWriter, r *http.Request) {
+	g.act(w, r, chess.Game.ClaimDraw)
+}
+
+// Resign ends the game as a loss for the player.
+func (g GameHandler) Resign(w http.ResponseWriter, r *http.Request) {
+	g.act(w, r, chess.Game.Resign)
+}
+
+// OfferDraw offers the opponent a draw.
+func (g GameHandler) OfferDraw(w http.ResponseWriter, r *http.Request) {
+	g.act(w, r, chess.Game.OfferDraw)
+}
+
+// AcceptDraw accepts the opponents draw offer and ends the game.
+func (g GameHandler) AcceptDraw(w http.ResponseWriter, r *http.Request) {
+	g.act(w, r, chess.Game.AcceptDraw)
+}
+
+// DeclineDraw rejects the opponents draw offer.
+func (g GameHandler) DeclineDraw(w http.ResponseWriter, r *http.Request) {
+	g.act(w, r, chess.Game.DeclineDraw)
+}
+
+// Abort cancels a game before both players have moved.
+func (g GameHandler) Abort(w http.ResponseWriter, r *http.Request) {
+	g.act(w, r, chess.Game.Abort)
+}
+
+// ProposeTakeback asks the opponent to take back the player's last move.
+func (g GameHandler) ProposeTakeback(w http.ResponseWriter, r *http.Request) {
+	g.act(w, r, g.takeback(chess.Game.ProposeTakeback))
+}
+
+// AcceptTakeback takes back the move the opponent asked to take back.
+func (g GameHandler) AcceptTakeback(w http.ResponseWriter, r *http.Request) {
+	g.act(w, r, g.takeback(chess.Game.AcceptTakeback))
+}
+
+// DeclineTakeback rejects the opponent's takeback proposal.
+func (g GameHandler) DeclineTakeback(w http.ResponseWriter, r *http.Request) {
+	g.act(w, r, chess.Game.DeclineTakeback)
+}
+
+// takeback stops a takeback action in rated games unless takebacks are
+// allowed in them.
+func (g GameHandler) takeback(action func(chess.Game, string, time.Time) error) func(chess.Game, string, time.Time) error {
+	return func(game chess.Game, playerId string, now time.Time) error {
+		if game.IsRated() && !g.ratedTakebacks {
+			return errRatedTakeback
+		}
+		return action(game, playerId, now)
+	}
+}
+
+// Rematch offers the opponent a rematch of a finished game or accepts the
+// opponent's offer. Accepting starts the rematch with the colors swapped and
+// responds with it, followers of the old game are sent its id in a rematch
+// message.
+func (g GameHandler) Rematch(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	gameId := chi.URLParam(r, "gameId")
+
+	p := getPlayer(w, r, g.ab)
+
+	game, err := g.findVisible(r, gameId, p)
+	if err != nil {
+		RespondError(ctx, w, err)
+		return
+	}
+
+	now := time.Now()
+	seen := len(game.Events(0))
+
+	rematch, err := game.Rematch(p.Id, primitive.NewObjectID(), now)
+	if err != nil {
+		RespondError(ctx, w, gameError(err))
+		return
+	}
+
+	// The rematch is created before the old game is saved so that the old
+	// game never points at a rematch that does not exist. Saving the old
+	// game only succeeds for one request, the rematch of any other request
+	// is deleted again.
+	if rematch != nil {
+		if err := g.games.Create(ctx, rematch); err != nil {
+			RespondError(ctx, w, errors.Wrap(err, "creating rematch"))
+			return
+		}
+	}
+
+	err = g.games.Update(ctx, game)
+	if err != nil {
+		if rematch != nil {
+			if err := g.games.Delete(ctx, rematch.ID()); err != nil {
+				log.Printf("deleting unused rematch %v: %v", rematch.ID(), err)
+			}
+		}
+		RespondError(ctx, w, saveError(err))
+		return
+	}
+
+	g.publishEvents(game, seen, now)
+
+	if rematch == nil {
+		Respond(ctx, w, game, http.StatusOK)
+		return
+	}
+
+	Respond(ctx, w, rematch, http.StatusCreated)
+	return
+}
+
+// act applies a players action to a game, saves it and tells followers
+// about it.
+func (g GameHandler) act(w http.ResponseWriter, r *http.Request, action func(chess.Game, string, time.Time) error) {
+	ctx := r.Context()
+
+	gameId := chi.URLParam(r, "gameId")
+
+	p := getPlayer(w, r, g.ab)
+
+	game, err := chess.FindById(ctx, g.games, gameId, p)
+	if err != nil {
+		RespondError(ctx, w, findError(err))
+		return
+	}
+
+	now := time.Now()
+	seen := len(game.Events(0))
+
+	err = action(game, p.Id, now)
+	if err != nil {
+		RespondError(ctx, w, gameError(err))
+		return
+	}
+
+	err = g.games.Update(ctx, game)
+	if err != nil {
+		RespondError(ctx, w, saveError(err))
+		return
+	}
+
+	g.publishEvents(game, seen, now)
+
+	Respond(ctx, w, game, http.StatusOK)
+	return
+}
+
+// publish sends a json encoded event to everyone following a game.
+func (g GameHandler) publish(gameId string, event string, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("encoding %v event: %v", event, err)
+		return
+	}
+
+	g.nc.Publish(fmt.Sprintf("game.%v.%v", gameId, event), data)
+}
+
+// findError converts errors from loading a game into errors for the client.
+func findError(err error) error {
+	if errors.Cause(err) == chess.ErrGameNotFound {
+		return Error{err, http.StatusNotFound, nil}
+	}
+
+	return errors.Wrap(err, "finding game")
+}
+
+// saveError converts errors from saving a game into errors for the client.
+// A game that was changed by another request is a conflict the client can
+// retry after reloading the game.
+func saveError(err error) error {
+	if errors.Cause(err) == chess.ErrConflict {
+		return Error{err, http.StatusConflict, nil}
+	}
+
+	return errors.Wrap(err, "saving game")
+}
+
+// gameError converts errors from the chess package into errors with
+// a status code for the client.
+func gameError(err error) error {
+	switch errors.Cause(err) {
+	case chess.ErrGameOver, chess.ErrFlagFall:
+		return Error{err, http.StatusConflict, nil}
+	case chess.ErrNotParticipant, errRatedTakeback:
+		return Error{err, http.StatusForbidden, nil}
+	case chess.ErrNoDrawClaim, chess.ErrNoDrawOffer, chess.ErrNoTakebackProposal, chess.ErrNothingToTakeBack, chess.ErrMoveIdReused:
+		return Error{err, http.StatusConflict, nil}
+	case chess.ErrNotStarted, chess.ErrCannotAbort, chess.ErrGameFull, chess.ErrNotOver, chess.ErrRematchStarted:
+		return Error{err, http.StatusConflict, nil}
+	case chess.ErrPromotionRequired, chess.ErrInvalidPromotion, chess.ErrPawnDrop, chess.ErrNotInPocket:
+		return Error{fmt.Errorf("unable to validate request"), http.StatusUnprocessableEntity, []FieldError{
+			{Field: "move", Error: err.Error()},
+		}}
+	case chess.ErrInvalidFen:
+		return Error{fmt.Errorf("unable to validate request"), http.StatusUnprocessableEntity, []FieldError{
+			{Field: "fen", Error: err.Error()},
+		}}
+	case chess.ErrUnknownColor:
+		return Error{fmt.Errorf("unable to validate request"), http.StatusUnprocessableEntity, []FieldError{
+			{Field: "color", Error: err.Error()},
+		}}
+	case chess.ErrUnknownVisibility:
+		return Error{fmt.Errorf("unable to validate request"), http.StatusUnprocessableEntity, []FieldError{
+			{Field: "visibility", Error: err.Error()},
+		}}
+	}
+
+	return Error{err, http.StatusUnprocessableEntity, nil}
+}
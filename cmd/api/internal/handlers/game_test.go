@@ -0,0 +1,341 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/schafer14/chess-serve/internal/chess"
+	"github.com/schafer14/chess-serve/internal/invite"
+	"github.com/volatiletech/authboss"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// gameServer serves the game routes from a store. Nobody is logged in so
+// every client plays as a guest with their own session cookie.
+func gameServer(t *testing.T, games chess.GameStore) *httptest.Server {
+	t.Helper()
+
+	g := GameHandler{
+		games:   games,
+		ab:      authboss.New(),
+		invites: invite.New([]byte("key"), time.Hour),
+	}
+
+	r := chi.NewRouter()
+	r.Get("/v1/games/{gameId}", g.Find)
+	r.Put("/v1/games/{gameId}/join", g.Join)
+	r.Put("/v1/games/{gameId}/move", g.Move)
+	r.Put("/v1/games/{gameId}/resign", g.Resign)
+	r.Put("/v1/games/{gameId}/accept-draw", g.AcceptDraw)
+	r.Put("/v1/games/{gameId}/decline-draw", g.DeclineDraw)
+	r.Put("/v1/games/{gameId}/abort", g.Abort)
+	r.Post("/v1/games/{gameId}/rematch", g.Rematch)
+	r.Post("/v1/games", g.Create)
+
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+// racingStore can make updates lose against another request that saves the
+// same game first.
+type racingStore struct {
+	chess.GameStore
+
+	mu    sync.Mutex
+	races int
+}
+
+// race makes the next update lose.
+func (s *racingStore) race() {
+	s.mu.Lock()
+	s.races++
+	s.mu.Unlock()
+}
+
+func (s *racingStore) Update(ctx context.Context, g chess.Game) error {
+	s.mu.Lock()
+	race := s.races > 0
+	if race {
+		s.races--
+	}
+	s.mu.Unlock()
+
+	if race {
+		other, err := s.Get(ctx, g.ID())
+		if err != nil {
+			return err
+		}
+		if err := s.GameStore.Update(ctx, other); err != nil {
+			return err
+		}
+	}
+
+	return s.GameStore.Update(ctx, g)
+}
+
+// client is a guest with their own session.
+func client(t *testing.T) *http.Client {
+	t.Helper()
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatalf("creating cookie jar: %v", err)
+	}
+
+	return &http.Client{Jar: jar}
+}
+
+// send makes a request and decodes the response into v when it is not nil.
+func send(t *testing.T, c *http.Client, method string, url string, body string, v interface{}) int {
+	t.Helper()
+
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("creating request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("%v %v: %v", method, url, err)
+	}
+	defer res.Body.Close()
+
+	if v != nil && res.StatusCode < 300 {
+		if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+			t.Fatalf("decoding response of %v %v: %v", method, url, err)
+		}
+	}
+
+	return res.StatusCode
+}
+
+// gameResponse is the part of a game the tests look at.
+type gameResponse struct {
+	Id      string   `json:"id"`
+	WhiteId string   `json:"whiteId"`
+	BlackId string   `json:"blackId"`
+	Moves   []string `json:"moves"`
+	San     []string `json:"san"`
+	Status  int      `json:"status"`
+}
+
+func TestCreateJoinMove(t *testing.T) {
+	srv := gameServer(t, chess.NewMemoryStore())
+	white, black, guest := client(t), client(t), client(t)
+
+	var created gameResponse
+	if status := send(t, white, http.MethodPost, srv.URL+"/v1/games", `{"color":"white"}`, &created); status != http.StatusOK {
+		t.Fatalf("creating game: got status %d, want %d", status, http.StatusOK)
+	}
+	if created.WhiteId == "" || created.BlackId != "" || created.Status != chess.StatusInitiating {
+		t.Fatalf("created %+v, want a game waiting for black", created)
+	}
+	game := srv.URL + "/v1/games/" + created.Id
+
+	var joined gameResponse
+	if status := send(t, black, http.MethodPut, game+"/join", "", &joined); status != http.StatusOK {
+		t.Fatalf("joining game: got status %d, want %d", status, http.StatusOK)
+	}
+	if joined.BlackId == "" || joined.BlackId == joined.WhiteId || joined.Status != chess.StatusInProgress {
+		t.Fatalf("joined %+v, want a game in progress with two players", joined)
+	}
+
+	tests := []struct {
+		name   string
+		client *http.Client
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"joining a full game", guest, http.MethodPut, "/join", "", http.StatusConflict},
+		{"black moving first", black, http.MethodPut, "/move", `{"move":"e7e5"}`, http.StatusUnprocessableEntity},
+		{"illegal move", white, http.MethodPut, "/move", `{"move":"e2e5"}`, http.StatusUnprocessableEntity},
+		{"white moving", white, http.MethodPut, "/move", `{"move":"e2e4"}`, http.StatusNoContent},
+		{"white moving twice", white, http.MethodPut, "/move", `{"move":"d2d4"}`, http.StatusUnprocessableEntity},
+		{"guest moving", guest, http.MethodPut, "/move", `{"move":"e7e5"}`, http.StatusUnprocessableEntity},
+		{"move at the wrong ply", black, http.MethodPut, "/move", `{"move":"e7e5","ply":0}`, http.StatusConflict},
+		{"black moving", black, http.MethodPut, "/move", `{"move":"e5","ply":1}`, http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		if status := send(t, tt.client, tt.method, game+tt.path, tt.body, nil); status != tt.status {
+			t.Errorf("%v: got status %d, want %d", tt.name, status, tt.status)
+		}
+	}
+
+	var played gameResponse
+	if status := send(t, guest, http.MethodGet, game, "", &played); status != http.StatusOK {
+		t.Fatalf("finding game: got status %d, want %d", status, http.StatusOK)
+	}
+	if strings.Join(played.Moves, " ") != "e2e4 e7e5" || strings.Join(played.San, " ") != "e4 e5" {
+		t.Errorf("got moves %v (%v), want e2e4 e7e5 (e4 e5)", played.Moves, played.San)
+	}
+}
+
+func TestGameActions(t *testing.T) {
+	srv := gameServer(t, chess.NewMemoryStore())
+	white, black := client(t), client(t)
+
+	var created gameResponse
+	if status := send(t, white, http.MethodPost, srv.URL+"/v1/games", `{"color":"white"}`, &created); status != http.StatusOK {
+		t.Fatalf("creating game: got status %d, want %d", status, http.StatusOK)
+	}
+	game := srv.URL + "/v1/games/" + created.Id
+	if status := send(t, black, http.MethodPut, game+"/join", "", nil); status != http.StatusOK {
+		t.Fatalf("joining game: got status %d, want %d", status, http.StatusOK)
+	}
+	missing := srv.URL + "/v1/games/" + primitive.NewObjectID().Hex()
+
+	tests := []struct {
+		name   string
+		client *http.Client
+		url    string
+		status int
+	}{
+		{"resigning an unknown game", white, missing + "/resign", http.StatusNotFound},
+		{"aborting an unknown game", white, missing + "/abort", http.StatusNotFound},
+		{"accepting a draw nobody offered", black, game + "/accept-draw", http.StatusConflict},
+		{"declining a draw nobody offered", black, game + "/decline-draw", http.StatusConflict},
+		{"resigning", black, game + "/resign", http.StatusOK},
+		{"resigning a finished game", white, game + "/resign", http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		if status := send(t, tt.client, http.MethodPut, tt.url, "", nil); status != tt.status {
+			t.Errorf("%v: got status %d, want %d", tt.name, status, tt.status)
+		}
+	}
+}
+
+func TestMoveConflict(t *testing.T) {
+	store := &racingStore{GameStore: chess.NewMemoryStore()}
+	srv := gameServer(t, store)
+	white, black := client(t), client(t)
+
+	var created gameResponse
+	if status := send(t, white, http.MethodPost, srv.URL+"/v1/games", `{"color":"white"}`, &created); status != http.StatusOK {
+		t.Fatalf("creating game: got status %d, want %d", status, http.StatusOK)
+	}
+	game := srv.URL + "/v1/games/" + created.Id
+	if status := send(t, black, http.MethodPut, game+"/join", "", nil); status != http.StatusOK {
+		t.Fatalf("joining game: got status %d, want %d", status, http.StatusOK)
+	}
+
+	store.race()
+	if status := send(t, white, http.MethodPut, game+"/move", `{"move":"e4"}`, nil); status != http.StatusConflict {
+		t.Errorf("moving in a game saved by someone else: got status %d, want %d", status, http.StatusConflict)
+	}
+	if status := send(t, white, http.MethodPut, game+"/move", `{"move":"e4","ply":0}`, nil); status != http.StatusNoContent {
+		t.Errorf("moving again: got status %d, want %d", status, http.StatusNoContent)
+	}
+	if status := send(t, black, http.MethodPut, game+"/move", `{"move":"e5","ply":0}`, nil); status != http.StatusConflict {
+		t.Errorf("moving at a stale ply: got status %d, want %d", status, http.StatusConflict)
+	}
+}
+
+func TestMoveIdempotencyKey(t *testing.T) {
+	srv := gameServer(t, chess.NewMemoryStore())
+	white, black := client(t), client(t)
+
+	var created gameResponse
+	if status := send(t, white, http.MethodPost, srv.URL+"/v1/games", `{"color":"white"}`, &created); status != http.StatusOK {
+		t.Fatalf("creating game: got status %d, want %d", status, http.StatusOK)
+	}
+	game := srv.URL + "/v1/games/" + created.Id
+	if status := send(t, black, http.MethodPut, game+"/join", "", nil); status != http.StatusOK {
+		t.Fatalf("joining game: got status %d, want %d", status, http.StatusOK)
+	}
+
+	tests := []struct {
+		name   string
+		client *http.Client
+		body   string
+		status int
+	}{
+		{"white moving", white, `{"move":"e4","moveId":"w1"}`, http.StatusNoContent},
+		{"white retrying the move", white, `{"move":"e4","moveId":"w1"}`, http.StatusNoContent},
+		{"white reusing the id for another move", white, `{"move":"d4","moveId":"w1"}`, http.StatusConflict},
+		{"black replaying the id of white", black, `{"move":"e4","moveId":"w1"}`, http.StatusUnprocessableEntity},
+		{"black moving with the same id", black, `{"move":"e5","moveId":"w1"}`, http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		if status := send(t, tt.client, http.MethodPut, game+"/move", tt.body, nil); status != tt.status {
+			t.Errorf("%v: got status %d, want %d", tt.name, status, tt.status)
+		}
+	}
+
+	var played gameResponse
+	if status := send(t, white, http.MethodGet, game, "", &played); status != http.StatusOK {
+		t.Fatalf("finding game: got status %d, want %d", status, http.StatusOK)
+	}
+	if got := strings.Join(played.San, " "); got != "e4 e5" {
+		t.Errorf("got moves %v, want e4 e5", got)
+	}
+}
+
+func TestRematchConflict(t *testing.T) {
+	store := &racingStore{GameStore: chess.NewMemoryStore()}
+	srv := gameServer(t, store)
+	white, black := client(t), client(t)
+
+	var created gameResponse
+	if status := send(t, white, http.MethodPost, srv.URL+"/v1/games", `{"color":"white"}`, &created); status != http.StatusOK {
+		t.Fatalf("creating game: got status %d, want %d", status, http.StatusOK)
+	}
+	game := srv.URL + "/v1/games/" + created.Id
+	var joined gameResponse
+	if status := send(t, black, http.MethodPut, game+"/join", "", &joined); status != http.StatusOK {
+		t.Fatalf("joining game: got status %d, want %d", status, http.StatusOK)
+	}
+	if status := send(t, black, http.MethodPut, game+"/resign", "", nil); status != http.StatusOK {
+		t.Fatalf("resigning: got status %d, want %d", status, http.StatusOK)
+	}
+	if status := send(t, white, http.MethodPost, game+"/rematch", "", nil); status != http.StatusOK {
+		t.Fatalf("offering rematch: got status %d, want %d", status, http.StatusOK)
+	}
+
+	// count counts the stored games.
+	count := func() int {
+		n := 0
+		err := store.List(context.Background(), chess.GameFilter{}, func(chess.Game) error {
+			n++
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("listing games: %v", err)
+		}
+		return n
+	}
+
+	store.race()
+	if status := send(t, black, http.MethodPost, game+"/rematch", "", nil); status != http.StatusConflict {
+		t.Errorf("accepting a rematch of a game saved by someone else: got status %d, want %d", status, http.StatusConflict)
+	}
+	if n := count(); n != 1 {
+		t.Errorf("got %d games after the conflict, want the rematch deleted", n)
+	}
+
+	var rematch gameResponse
+	if status := send(t, black, http.MethodPost, game+"/rematch", "", &rematch); status != http.StatusCreated {
+		t.Fatalf("accepting rematch: got status %d, want %d", status, http.StatusCreated)
+	}
+	if rematch.WhiteId != joined.BlackId || rematch.BlackId != joined.WhiteId {
+		t.Errorf("got %v against %v, want the colors swapped", rematch.WhiteId, rematch.BlackId)
+	}
+	if n := count(); n != 2 {
+		t.Errorf("got %d games after the rematch, want 2", n)
+	}
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/pkg/errors"
+	"github.com/schafer14/chess-serve/internal/chess"
+	"github.com/schafer14/chess-serve/internal/invite"
+)
+
+// Invite lets someone who is not playing in a private game join or follow
+// it. The token is sent as the invite query parameter.
+type Invite struct {
+	Token   string    `json:"token"`
+	Expires time.Time `json:"expires"`
+}
+
+// Invite makes an invite to a game. Only the players of a game can invite
+// others to it.
+func (g GameHandler) Invite(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	now := time.Now()
+
+	gameId := chi.URLParam(r, "gameId")
+
+	p := getPlayer(w, r, g.ab)
+
+	game, err := g.findVisible(r, gameId, p)
+	if err != nil {
+		RespondError(ctx, w, err)
+		return
+	}
+	if !game.Plays(p.Id) {
+		RespondError(ctx, w, gameError(chess.ErrNotParticipant))
+		return
+	}
+
+	token, expires := g.invites.Sign(game.ID(), now)
+
+	Respond(ctx, w, Invite{Token: token, Expires: expires}, http.StatusOK)
+	return
+}
+
+// findVisible loads a game the player is allowed to see. Private games can
+// only be seen by their players and by players with an invite, everyone
+// else is told the game does not exist.
+func (g GameHandler) findVisible(r *http.Request, gameId string, p chess.Player) (chess.Game, error) {
+	game, err := chess.FindById(r.Context(), g.games, gameId, p)
+	if errors.Cause(err) == chess.ErrGameNotFound {
+		return nil, Error{err, http.StatusNotFound, nil}
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "finding game")
+	}
+
+	if game.Visible(p.Id) {
+		return game, nil
+	}
+
+	token := r.URL.Query().Get("invite")
+	switch err := g.invites.Verify(token, game.ID(), time.Now()); err {
+	case nil:
+		return game, nil
+	case invite.ErrExpiredToken:
+		return nil, Error{err, http.StatusForbidden, nil}
+	}
+
+	return nil, Error{chess.ErrGameNotFound, http.StatusNotFound, nil}
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/schafer14/chess-serve/internal/chess"
+)
+
+const (
+	// defaultListLimit is how many games are listed when the client does
+	// not ask for a number.
+	defaultListLimit = 20
+
+	// maxListLimit is the most games listed at once.
+	maxListLimit = 100
+)
+
+// statuses are the names of the statuses games can be listed by.
+var statuses = map[string]int{
+	"initiating": chess.StatusInitiating,
+	"inProgress": chess.StatusInProgress,
+	"done":       chess.StatusDone,
+}
+
+// GameList is a page of games. Next is the cursor of the next page and is
+// left out on the last page.
+type GameList struct {
+	Games []chess.Game `json:"games"`
+	Next  string       `json:"next,omitempty"`
+}
+
+// List finds games. The query can filter the games by
+//
+//	status   initiating, inProgress or done, several separated by commas
+//	player   the id of a player playing either side
+//	result   1-0, 0-1, 1/2-1/2 or *
+//	variant  the name of a variant
+//	from, to a date range as RFC 3339 times or dates like 2020-06-30
+//	rated    true for rated games and false for casual games
+//
+// Games are sorted by date with sort=date or sort=-date for the newest
+// first, which is the default. Pages have limit games and the next page is
+// found by passing the next cursor of a page as cursor. Unlisted and private
+// games are only listed for their players.
+func (g GameHandler) List(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	f, err := listFilter(r.URL.Query())
+	if err != nil {
+		RespondError(ctx, w, err)
+		return
+	}
+
+	// One more game than asked for tells if there is another page.
+	limit := f.Limit
+	f.Limit++
+
+	p := getPlayer(w, r, g.ab)
+	f.Viewer = p.Id
+
+	games, err := chess.FindGames(ctx, g.games, f, p)
+	if err != nil {
+		RespondError(ctx, w, errors.Wrap(err, "listing games"))
+		return
+	}
+
+	list := GameList{Games: games}
+	if len(games) > limit {
+		list.Games = games[:limit]
+		list.Next = chess.CursorFor(games[limit-1]).String()
+	}
+
+	Respond(ctx, w, list, http.StatusOK)
+	return
+}
+
+// listFilter reads the filter of a game listing from a query.
+func listFilter(q url.Values) (chess.GameFilter, error) {
+	f := chess.GameFilter{
+		Player:  q.Get("player"),
+		Variant: q.Get("variant"),
+		Newest:  true,
+		Limit:   defaultListLimit,
+	}
+	var fields []FieldError
+	invalid := func(field string, msg string) {
+		fields = append(fields, FieldError{Field: field, Error: msg})
+	}
+
+	if s := q.Get("status"); s != "" {
+		for _, name := range strings.Split(s, ",") {
+			status, ok := statuses[name]
+			if !ok {
+				invalid("status", "status must be initiating, inProgress or done")
+				break
+			}
+			f.Status = append(f.Status, status)
+		}
+	}
+
+	switch result := chess.Result(q.Get("result")); result {
+	case "":
+	case chess.ResultWhiteWins, chess.ResultBlackWins, chess.ResultDraw, chess.ResultNone:
+		f.Result = result
+	default:
+		invalid("result", "result must be 1-0, 0-1, 1/2-1/2 or *")
+	}
+
+	for _, field := range []string{"from", "to"} {
+		s := q.Get(field)
+		if s == "" {
+			continue
+		}
+		date, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			date, err = time.Parse("2006-01-02", s)
+		}
+		if err != nil {
+			invalid(field, field+" must be an RFC 3339 time or a date like 2020-06-30")
+			continue
+		}
+		if field == "from" {
+			f.From = date
+		} else {
+			f.To = date
+		}
+	}
+
+	if s := q.Get("rated"); s != "" {
+		rated, err := strconv.ParseBool(s)
+		if err != nil {
+			invalid("rated", "rated must be true or false")
+		}
+		f.Rated = &rated
+	}
+
+	switch q.Get("sort") {
+	case "", "-date":
+	case "date":
+		f.Newest = false
+	default:
+		invalid("sort", "sort must be date or -date")
+	}
+
+	if s := q.Get("cursor"); s != "" {
+		cursor, err := chess.ParseCursor(s)
+		if err != nil {
+			invalid("cursor", err.Error())
+		}
+		f.After = &cursor
+	}
+
+	if s := q.Get("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit < 1 || limit > maxListLimit {
+			invalid("limit", fmt.Sprintf("limit must be from 1 to %d", maxListLimit))
+		}
+		f.Limit = limit
+	}
+
+	if len(fields) > 0 {
+		return f, Error{fmt.Errorf("unable to validate request"), http.StatusUnprocessableEntity, fields}
+	}
+
+	return f, nil
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/nats-io/nats.go"
+	"github.com/pkg/errors"
+	"github.com/schafer14/chess-serve/internal/lobby"
+	"github.com/volatiletech/authboss"
+)
+
+type LobbyHandler struct {
+	lobby *lobby.Lobby
+	nc    *nats.Conn
+	ab    *authboss.Authboss
+}
+
+// NewSeek are the settings of the game a player is looking for. Color is
+// the color the seeker wants to play, random if it is left out.
+type NewSeek struct {
+	TimeControl *TimeControl `json:"timeControl"`
+	Variant     string       `json:"variant" validate:"omitempty,oneof=standard chess960 kingOfTheHill threeCheck racingKings crazyhouse"`
+	Color       string       `json:"color" validate:"omitempty,oneof=white black random"`
+	Rated       bool         `json:"rated"`
+	RatingRange *RatingRange `json:"ratingRange"`
+}
+
+// RatingRange limits who can accept a seek to players rated from min to
+// max.
+type RatingRange struct {
+	Min int `json:"min" validate:"min=0"`
+	Max int `json:"max" validate:"gtefield=Min"`
+}
+
+// Seeks lists the open seeks.
+func (l LobbyHandler) Seeks(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	seeks, err := l.lobby.Seeks(ctx)
+	if err != nil {
+		RespondError(ctx, w, errors.Wrap(err, "listing seeks"))
+		return
+	}
+
+	Respond(ctx, w, seeks, http.StatusOK)
+	return
+}
+
+// Seek posts a seek in the lobby.
+func (l LobbyHandler) Seek(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var n NewSeek
+	if r.ContentLength != 0 {
+		if err := Decode(r, &n); err != nil {
+			RespondError(ctx, w, err)
+			return
+		}
+	}
+
+	// Seeks are checked like new games so that accepting them can not fail
+	// on their settings.
+	opts, err := NewGame{TimeControl: n.TimeControl, Variant: n.Variant, Rated: n.Rated}.options()
+	if err != nil {
+		RespondError(ctx, w, err)
+		return
+	}
+
+	s := lobby.Seek{
+		TimeControl: opts.TimeControl,
+		Variant:     opts.Variant,
+		Color:       n.Color,
+		Rated:       opts.Rated,
+	}
+	if rr := n.RatingRange; rr != nil {
+		s.RatingRange = &lobby.RatingRange{Min: rr.Min, Max: rr.Max}
+	}
+
+	p := getPlayer(w, r, l.ab)
+
+	s, err = l.lobby.Post(ctx, s, p, time.Now())
+	if err != nil {
+		RespondError(ctx, w, err)
+		return
+	}
+
+	Respond(ctx, w, s, http.StatusOK)
+	return
+}
+
+// Cancel removes a seek from the lobby.
+func (l LobbyHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	seekId := chi.URLParam(r, "seekId")
+
+	p := getPlayer(w, r, l.ab)
+
+	if err := l.lobby.Cancel(ctx, seekId, p.Id); err != nil {
+		RespondError(ctx, w, lobbyError(err))
+		return
+	}
+
+	Respond(ctx, w, nil, http.StatusNoContent)
+	return
+}
+
+// Accept starts the game of a seek. The seeker finds out about the game
+// from the lobby.accepted message.
+func (l LobbyHandler) Accept(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	seekId := chi.URLParam(r, "seekId")
+
+	p := getPlayer(w, r, l.ab)
+
+	game, err := l.lobby.Accept(ctx, seekId, p, time.Now())
+	if err != nil {
+		RespondError(ctx, w, lobbyError(err))
+		return
+	}
+
+	Respond(ctx, w, game, http.StatusOK)
+	return
+}
+
+// Follow uses websockets to get updates of the lobby in real time. The open
+// seeks are sent first as a seeks message.
+func (l LobbyHandler) Follow(w http.ResponseWriter, r *http.Request) {
+	upgrader.CheckOrigin = func(r *http.Request) bool { return true }
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	// Messages from nats and the open seeks are written by different
+	// goroutines but a connection can only have one writer at a time.
+	var mu sync.Mutex
+	write := func(msg WsMessage) {
+		mu.Lock()
+		defer mu.Unlock()
+		conn.WriteJSON(msg)
+	}
+
+	// Subscribing before listing the seeks means no seek is missed, a seek
+	// posted in between is sent twice.
+	sub, err := l.nc.Subscribe("lobby.*", func(m *nats.Msg) {
+		parts := strings.Split(m.Subject, ".")
+		write(WsMessage{parts[len(parts)-1], string(m.Data)})
+	})
+	if err != nil {
+		log.Println(err)
+		conn.Close()
+		return
+	}
+
+	seeks, err := l.lobby.Seeks(r.Context())
+	if err != nil {
+		log.Println(err)
+	} else if data, err := json.Marshal(seeks); err != nil {
+		log.Println(err)
+	} else {
+		write(WsMessage{"seeks", string(data)})
+	}
+
+	go func() {
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				sub.Unsubscribe()
+				conn.Close()
+				return
+			}
+		}
+	}()
+}
+
+// lobbyError converts errors from the lobby into errors for the client.
+func lobbyError(err error) error {
+	switch errors.Cause(err) {
+	case lobby.ErrSeekNotFound:
+		return Error{err, http.StatusNotFound, nil}
+	case lobby.ErrOwnSeek:
+		return Error{err, http.StatusConflict, nil}
+	case lobby.ErrNotSeeker, lobby.ErrRatingOutOfRange:
+		return Error{err, http.StatusForbidden, nil}
+	}
+
+	return errors.Wrap(err, "accepting seek")
+}
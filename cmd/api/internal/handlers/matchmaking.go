@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/pkg/errors"
+	"github.com/schafer14/chess-serve/internal/matchmaking"
+	"github.com/volatiletech/authboss"
+)
+
+type QueueHandler struct {
+	queue *matchmaking.Queue
+	nc    *nats.Conn
+	ab    *authboss.Authboss
+}
+
+// QueueRequest is the game a player wants to be paired into.
+type QueueRequest struct {
+	TimeControl *TimeControl `json:"timeControl"`
+	Variant     string       `json:"variant" validate:"omitempty,oneof=standard chess960 kingOfTheHill threeCheck racingKings crazyhouse"`
+	Rated       bool         `json:"rated"`
+}
+
+// Join puts the player in the queue for a game. The player is told about
+// the game they are paired into by a matched message on the queue
+// websocket.
+func (q QueueHandler) Join(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var n QueueRequest
+	if r.ContentLength != 0 {
+		if err := Decode(r, &n); err != nil {
+			RespondError(ctx, w, err)
+			return
+		}
+	}
+
+	opts, err := NewGame{TimeControl: n.TimeControl, Variant: n.Variant, Rated: n.Rated}.options()
+	if err != nil {
+		RespondError(ctx, w, err)
+		return
+	}
+
+	pool := matchmaking.Pool{Variant: opts.Variant, Rated: opts.Rated}
+	if opts.TimeControl != nil {
+		pool.TimeControl = *opts.TimeControl
+	}
+
+	p := getPlayer(w, r, q.ab)
+
+	ticket, err := q.queue.Join(ctx, p, pool, time.Now())
+	if err != nil {
+		RespondError(ctx, w, err)
+		return
+	}
+
+	Respond(ctx, w, ticket, http.StatusOK)
+	return
+}
+
+// Leave takes the player out of the queue.
+func (q QueueHandler) Leave(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	p := getPlayer(w, r, q.ab)
+
+	if err := q.queue.Leave(ctx, p.Id); err != nil {
+		if errors.Cause(err) == matchmaking.ErrNotQueued {
+			RespondError(ctx, w, Error{err, http.StatusNotFound, nil})
+			return
+		}
+		RespondError(ctx, w, errors.Wrap(err, "leaving queue"))
+		return
+	}
+
+	Respond(ctx, w, nil, http.StatusNoContent)
+	return
+}
+
+// Follow uses websockets to tell the player about the games they are paired
+// into. Only players who are following can be told about their game, so
+// closing the connection takes the player out of the queue.
+func (q QueueHandler) Follow(w http.ResponseWriter, r *http.Request) {
+	p := getPlayer(w, r, q.ab)
+
+	upgrader.CheckOrigin = func(r *http.Request) bool { return true }
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	// A connection can only have one writer at a time.
+	var mu sync.Mutex
+	write := func(msg WsMessage) {
+		mu.Lock()
+		defer mu.Unlock()
+		conn.WriteJSON(msg)
+	}
+
+	sub, err := q.nc.Subscribe(matchmaking.Subject(p.Id)+".*", func(m *nats.Msg) {
+		parts := strings.Split(m.Subject, ".")
+		write(WsMessage{parts[len(parts)-1], string(m.Data)})
+	})
+	if err != nil {
+		log.Println(err)
+		conn.Close()
+		return
+	}
+
+	go func() {
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				sub.Unsubscribe()
+				conn.Close()
+				q.queue.Leave(context.Background(), p.Id)
+				return
+			}
+		}
+	}()
+}
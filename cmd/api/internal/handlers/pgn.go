@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/pkg/errors"
+	"github.com/schafer14/chess-serve/internal/chess"
+	"github.com/schafer14/chess-serve/internal/pgn"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// pgnContentType is the media type of portable game notation files.
+const pgnContentType = "application/x-chess-pgn"
+
+// PGN exports a game in portable game notation.
+func (g GameHandler) PGN(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	gameId := chi.URLParam(r, "gameId")
+
+	p := getPlayer(w, r, g.ab)
+
+	game, err := g.findVisible(r, gameId, p)
+	if err != nil {
+		RespondError(ctx, w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", pgnContentType)
+	w.WriteHeader(http.StatusOK)
+
+	if err := game.WritePGN(w); err != nil {
+		log.Println(err)
+	}
+}
+
+// PlayerPGN streams every game a player has played in as a single portable
+// game notation file. Unlisted and private games are left out unless the
+// player asks for their own games.
+func (g GameHandler) PlayerPGN(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	playerId := chi.URLParam(r, "playerId")
+
+	p := getPlayer(w, r, g.ab)
+
+	w.Header().Set("Content-Type", pgnContentType)
+	w.WriteHeader(http.StatusOK)
+
+	flusher, _ := w.(http.Flusher)
+
+	err := g.games.List(ctx, chess.GameFilter{Player: playerId, Viewer: p.Id}, func(game chess.Game) error {
+		if err := game.WritePGN(w); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		// The response has already started so the best that can be done is
+		// to stop the stream.
+		log.Println(errors.Wrap(err, "exporting games"))
+	}
+}
+
+// ImportPGN is a request to import the games of a PGN file.
+type ImportPGN struct {
+	PGN string `json:"pgn" validate:"required"`
+}
+
+// ImportedGame reports on a single game created from a PGN file. Games that
+// can not be played at all, like games of other variants, are not created and
+// have no id.
+type ImportedGame struct {
+	Id     string          `json:"id,omitempty"`
+	White  string          `json:"white"`
+	Black  string          `json:"black"`
+	Result chess.Result    `json:"result"`
+	Moves  int             `json:"moves"`
+	Errors []pgn.MoveError `json:"errors"`
+}
+
+// Import creates games from a PGN file. Every game in the file is imported
+// up to its first illegal move. The result of a game is only kept if all of
+// its main line could be played.
+func (g GameHandler) Import(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	now := time.Now()
+
+	var req ImportPGN
+	if err := Decode(r, &req); err != nil {
+		RespondError(ctx, w, err)
+		return
+	}
+
+	p := getPlayer(w, r, g.ab)
+
+	games, err := pgn.Parse(strings.NewReader(req.PGN))
+	if err != nil {
+		RespondError(ctx, w, Error{err, http.StatusUnprocessableEntity, nil})
+		return
+	}
+
+	imported := []ImportedGame{}
+	for _, pg := range games {
+		moves, moveErrs := pg.Play()
+
+		imp := chess.Imported{
+			White:       tagOr(pg.Tags, "White", "Unknown"),
+			Black:       tagOr(pg.Tags, "Black", "Unknown"),
+			Date:        now,
+			Fen:         pg.Tags["FEN"],
+			Moves:       moves,
+			Result:      chess.Result(pg.Result),
+			Termination: strings.ToLower(pg.Tags["Termination"]),
+			Owner:       p,
+		}
+		if date, err := time.Parse("2006.01.02", pg.Tags["Date"]); err == nil {
+			imp.Date = date
+		}
+		if len(moves) < len(pg.Moves) {
+			imp.Result = chess.ResultNone
+		}
+
+		// Games that can not be played at all are reported without being
+		// created.
+		if len(moveErrs) > 0 && moveErrs[0].Ply == 0 {
+			imported = append(imported, ImportedGame{
+				White:  imp.White,
+				Black:  imp.Black,
+				Result: chess.ResultNone,
+				Errors: moveErrs,
+			})
+			continue
+		}
+
+		game := chess.Import(primitive.NewObjectID(), imp)
+		if err := g.games.Create(ctx, game); err != nil {
+			RespondError(ctx, w, errors.Wrap(err, "saving imported game"))
+			return
+		}
+
+		if moveErrs == nil {
+			moveErrs = []pgn.MoveError{}
+		}
+		imported = append(imported, ImportedGame{
+			Id:     game.ID(),
+			White:  imp.White,
+			Black:  imp.Black,
+			Result: game.Outcome().Result,
+			Moves:  len(moves),
+			Errors: moveErrs,
+		})
+	}
+
+	Respond(ctx, w, imported, http.StatusCreated)
+}
+
+// tagOr gets the value of a tag or a default when the tag is missing or
+// unknown.
+func tagOr(tags map[string]string, name string, def string) string {
+	if v := tags[name]; v != "" && v != "?" {
+		return v
+	}
+	return def
+}
@@ -9,6 +9,11 @@ import (
 	"github.com/go-chi/cors"
 	"github.com/gorilla/context"
 	"github.com/nats-io/nats.go"
+	"github.com/schafer14/chess-serve/internal/challenge"
+	"github.com/schafer14/chess-serve/internal/chess"
+	"github.com/schafer14/chess-serve/internal/invite"
+	"github.com/schafer14/chess-serve/internal/lobby"
+	"github.com/schafer14/chess-serve/internal/matchmaking"
 	"github.com/volatiletech/authboss"
 	"github.com/volatiletech/authboss/confirm"
 	"github.com/volatiletech/authboss/expire"
@@ -17,12 +22,15 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
+// streamTimeout is how long a stream of many games can take.
+const streamTimeout = 5 * time.Minute
+
 type Collections struct {
 	Observations string
 	People       string
 }
 
-func API(build string, db *mongo.Database, ab *authboss.Authboss, nc *nats.Conn, cfg Collections, corsMid *cors.Cors, version string) chi.Router {
+func API(build string, db *mongo.Database, games chess.GameStore, lob *lobby.Lobby, queue *matchmaking.Queue, challenges *challenge.Challenges, invites *invite.Signer, ratedTakebacks bool, ab *authboss.Authboss, nc *nats.Conn, cfg Collections, corsMid *cors.Cors, version string) chi.Router {
 	r := chi.NewRouter()
 
 	// Middleware
@@ -33,7 +41,6 @@ func API(build string, db *mongo.Database, ab *authboss.Authboss, nc *nats.Conn,
 	r.Use(middleware.AllowContentType("application/json"))
 	r.Use(middleware.Throttle(50))
 	r.Use(corsMid.Handler)
-	r.Use(middleware.Timeout(time.Second))
 	r.Use(middleware.Compress(5))
 	r.Use(middleware.Recoverer)
 	r.Use(ab.LoadClientStateMiddleware)
@@ -41,12 +48,19 @@ func API(build string, db *mongo.Database, ab *authboss.Authboss, nc *nats.Conn,
 
 	authHandler := AuthHandler{ab}
 	checkHandler := Check{build, db, version}
-	gameHandler := GameHandler{db.Collection("games"), nc, ab}
+	gameHandler := GameHandler{games, nc, ab, invites, ratedTakebacks}
+	lobbyHandler := LobbyHandler{lob, nc, ab}
+	queueHandler := QueueHandler{queue, nc, ab}
+	challengeHandler := ChallengeHandler{challenges, nc, ab}
+
+	// Every request other than the streams of many games has to finish
+	// within a second.
+	api := r.With(middleware.Timeout(time.Second))
 
 	// ======================================
 	// Protected routes
 	// ======================================
-	r.Group(func(r chi.Router) {
+	api.Group(func(r chi.Router) {
 		r.Use(authboss.Middleware2(ab, authboss.RequireNone, authboss.RespondUnauthorized))
 		r.Use(lock.Middleware(ab))
 		r.Use(confirm.Middleware(ab))
@@ -59,7 +73,7 @@ func API(build string, db *mongo.Database, ab *authboss.Authboss, nc *nats.Conn,
 	// ======================================
 	// Auth routes
 	// ======================================
-	r.Group(func(r chi.Router) {
+	api.Group(func(r chi.Router) {
 		r.Use(authboss.ModuleListMiddleware(ab))
 		r.Mount("/v1/auth", http.StripPrefix("/v1/auth", ab.Config.Core.Router))
 	})
@@ -69,20 +83,65 @@ func API(build string, db *mongo.Database, ab *authboss.Authboss, nc *nats.Conn,
 	// ======================================
 
 	// Game handler
-	r.Route("/v1/games", func(r chi.Router) {
+	api.Route("/v1/games", func(r chi.Router) {
+		r.Get("/", gameHandler.List)
 		r.Get("/{gameId}", gameHandler.Find)
 		r.Get("/{gameId}/follow", gameHandler.Follow)
 		r.Get("/{gameId}/fen", gameHandler.Fen)
+		r.Get("/{gameId}/pgn", gameHandler.PGN)
+		r.Get("/{gameId}/events", gameHandler.Events)
+		r.Post("/{gameId}/invite", gameHandler.Invite)
+		r.Post("/{gameId}/rematch", gameHandler.Rematch)
 		r.Put("/{gameId}/join", gameHandler.Join)
 		r.Put("/{gameId}/move", gameHandler.Move)
+		r.Put("/{gameId}/claim-draw", gameHandler.ClaimDraw)
+		r.Put("/{gameId}/resign", gameHandler.Resign)
+		r.Put("/{gameId}/offer-draw", gameHandler.OfferDraw)
+		r.Put("/{gameId}/accept-draw", gameHandler.AcceptDraw)
+		r.Put("/{gameId}/decline-draw", gameHandler.DeclineDraw)
+		r.Put("/{gameId}/abort", gameHandler.Abort)
+		r.Put("/{gameId}/propose-takeback", gameHandler.ProposeTakeback)
+		r.Put("/{gameId}/accept-takeback", gameHandler.AcceptTakeback)
+		r.Put("/{gameId}/decline-takeback", gameHandler.DeclineTakeback)
 		r.Post("/", gameHandler.Create)
+		r.Post("/import", gameHandler.Import)
 	})
 
+	// Lobby handler
+	api.Route("/v1/lobby", func(r chi.Router) {
+		r.Get("/follow", lobbyHandler.Follow)
+		r.Get("/seeks", lobbyHandler.Seeks)
+		r.Post("/seeks", lobbyHandler.Seek)
+		r.Put("/seeks/{seekId}/accept", lobbyHandler.Accept)
+		r.Delete("/seeks/{seekId}", lobbyHandler.Cancel)
+	})
+
+	// Matchmaking handler
+	api.Route("/v1/queue", func(r chi.Router) {
+		r.Get("/follow", queueHandler.Follow)
+		r.Put("/", queueHandler.Join)
+		r.Delete("/", queueHandler.Leave)
+	})
+
+	// Challenge handler
+	api.Route("/v1/challenges", func(r chi.Router) {
+		r.Get("/", challengeHandler.Pending)
+		r.Get("/follow", challengeHandler.Follow)
+		r.Post("/", challengeHandler.Create)
+		r.Put("/{challengeId}/accept", challengeHandler.Accept)
+		r.Put("/{challengeId}/decline", challengeHandler.Decline)
+		r.Put("/{challengeId}/cancel", challengeHandler.Cancel)
+	})
+
+	// Player handler. Streaming every game of a player can take much longer
+	// than other requests.
+	r.With(middleware.Timeout(streamTimeout)).Get("/v1/players/{playerId}/games.pgn", gameHandler.PlayerPGN)
+
 	// Health Check
-	r.Get("/health", checkHandler.Health)
-	r.Get("/v1/health", checkHandler.Health)
-	r.Get("/version", checkHandler.Version)
-	r.Get("/v1/version", checkHandler.Version)
+	api.Get("/health", checkHandler.Health)
+	api.Get("/v1/health", checkHandler.Health)
+	api.Get("/version", checkHandler.Version)
+	api.Get("/v1/version", checkHandler.Version)
 
 	return r
 }
@@ -6,6 +6,7 @@ import (
 	"expvar"
 	"fmt"
 	"log"
+	"math/rand"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -14,7 +15,13 @@ import (
 	"github.com/nats-io/nats.go"
 	"github.com/schafer14/chess-serve/cmd/api/internal/handlers"
 	"github.com/schafer14/chess-serve/internal/auth"
+	"github.com/schafer14/chess-serve/internal/challenge"
+	"github.com/schafer14/chess-serve/internal/chess"
+	"github.com/schafer14/chess-serve/internal/invite"
+	"github.com/schafer14/chess-serve/internal/lobby"
+	"github.com/schafer14/chess-serve/internal/matchmaking"
 	"github.com/schafer14/chess-serve/internal/platform/database"
+	"github.com/schafer14/chess-serve/internal/sweeper"
 
 	"github.com/ardanlabs/conf"
 	"github.com/go-chi/cors"
@@ -48,11 +55,15 @@ func main() {
 func run() error {
 	ctx := context.Background()
 
+	// Random numbers pick things like Chess960 start positions.
+	rand.Seed(time.Now().UnixNano())
+
 	// =============================================== //
 	// Read Configuration
 	// =============================================== //
 	var cfg struct {
 		APIHost string `conf:"default:0.0.0.0:3000"`
+		Store   string `conf:"default:mongo,help:where games, seeks, the queue and challenges are kept: mongo or memory"`
 		Cors    struct {
 			AllowedHosts []string
 		}
@@ -75,6 +86,27 @@ func run() error {
 		Nats struct {
 			Server string `json:"default:nats://localhost:4222"`
 		}
+		Sweeper struct {
+			Interval   time.Duration `conf:"default:1s"`
+			FirstMove  time.Duration `conf:"default:30s"`
+			Disconnect time.Duration `conf:"default:60s"`
+		}
+		Lobby struct {
+			SeekLifetime time.Duration `conf:"default:10m,help:how long seeks stay open in the lobby"`
+		}
+		Matchmaking struct {
+			Interval time.Duration `conf:"default:1s,help:how often waiting players are paired"`
+		}
+		Invite struct {
+			Key      string        `conf:"noprint,help:base64 encoded secret of at least 32 bytes that signs invites"`
+			Lifetime time.Duration `conf:"default:24h,help:how long invites to private games are valid"`
+		}
+		Takeback struct {
+			Rated bool `conf:"default:false,help:allow takebacks in rated games"`
+		}
+		Challenge struct {
+			Timeout time.Duration `conf:"default:2m,help:how long challenges wait for an answer"`
+		}
 	}
 
 	if err := conf.Parse(os.Args[1:], "CHESS", &cfg); err != nil {
@@ -103,6 +135,23 @@ func run() error {
 	}
 	log.Printf("main : Config :\n%v\n", out)
 
+	// =============================================== //
+	// Configure Invites
+	// =============================================== //
+	// Every server has to sign invites with the same key so there is no
+	// default for it.
+	if cfg.Invite.Key == "" {
+		return errors.New("invite key is not set: set CHESS_INVITE_KEY or --invite-key")
+	}
+	inviteKey, err := base64.StdEncoding.DecodeString(cfg.Invite.Key)
+	if err != nil {
+		return errors.Wrap(err, "decoding invite key")
+	}
+	if len(inviteKey) < 32 {
+		return errors.New("invite key must be at least 32 bytes")
+	}
+	invites := invite.New(inviteKey, cfg.Invite.Lifetime)
+
 	// =============================================== //
 	// Configure NATS
 	// =============================================== //
@@ -118,6 +167,41 @@ func run() error {
 		return errors.Wrap(err, "connecting to db")
 	}
 
+	// Users are always kept in mongo, games, seeks, the matchmaking queue and
+	// challenges can be kept in memory instead. Only a single server can run
+	// when they are in memory.
+	var games chess.GameStore
+	var seeks lobby.Store
+	var tickets matchmaking.Store
+	var pending challenge.Store
+	switch cfg.Store {
+	case "mongo":
+		store := chess.NewMongoStore(db.Collection("games"))
+		if err := store.EnsureIndexes(ctx); err != nil {
+			return errors.Wrap(err, "creating game indexes")
+		}
+		games = store
+		seeks = lobby.NewMongoStore(db.Collection("seeks"))
+		queueStore := matchmaking.NewMongoStore(db.Collection("tickets"), db.Collection("pairings"))
+		if err := queueStore.EnsureIndexes(ctx); err != nil {
+			return errors.Wrap(err, "creating pairing indexes")
+		}
+		tickets = queueStore
+		challengeStore := challenge.NewMongoStore(db.Collection("challenges"))
+		if err := challengeStore.EnsureIndexes(ctx); err != nil {
+			return errors.Wrap(err, "creating challenge indexes")
+		}
+		pending = challengeStore
+	case "memory":
+		log.Println("main : Games, seeks, the queue and challenges are kept in memory and are lost on shutdown")
+		games = chess.NewMemoryStore()
+		seeks = lobby.NewMemoryStore()
+		tickets = matchmaking.NewMemoryStore()
+		pending = challenge.NewMemoryStore()
+	default:
+		return errors.Errorf("unknown store %q: expected mongo or memory", cfg.Store)
+	}
+
 	// =============================================== //
 	// Configure Authentication
 	// =============================================== //
@@ -132,7 +216,7 @@ func run() error {
 	cstore.Options.Secure = false
 	cstore.MaxAge(int((30 * 24 * time.Hour) / time.Second))
 
-	ab.Config.Storage.Server = auth.NewStorer(db, auth.CollectionConfiguration{cfg.Database.Collections.Users, cfg.Database.Collections.Sessions})
+	ab.Config.Storage.Server = auth.NewStorer(db, auth.CollectionConfiguration{Users: cfg.Database.Collections.Users, Sessions: cfg.Database.Collections.Sessions})
 	ab.Config.Storage.SessionState = sessionStorer
 	ab.Config.Storage.CookieState = abclientstate.NewCookieStorer(cookieStoreKey, nil)
 	ab.Config.Modules.RecoverLoginAfterRecovery = false
@@ -160,11 +244,49 @@ func run() error {
 	cors := cors.New(cors.Options{
 		AllowedOrigins:   cfg.Cors.AllowedHosts,
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{},
+		AllowedHeaders:   []string{"Accept", "Content-Type", "X-Requested-With", "Idempotency-Key"},
 		AllowCredentials: true,
 		MaxAge:           300, // Maximum value not ignored by any of major browsers
 	})
 
+	// =============================================== //
+	// Start Sweeper
+	// =============================================== //
+	log.Println("main : Started : Initializing game sweeper")
+
+	limits := chess.Abandonment{
+		FirstMove:  cfg.Sweeper.FirstMove,
+		Disconnect: cfg.Sweeper.Disconnect,
+	}
+	go sweeper.New(games, nc, cfg.Sweeper.Interval, limits).Run(ctx)
+
+	// =============================================== //
+	// Start Lobby
+	// =============================================== //
+	log.Println("main : Started : Initializing lobby")
+
+	if cfg.Lobby.SeekLifetime <= 0 {
+		return errors.New("seek lifetime must be positive")
+	}
+	lob := lobby.New(seeks, games, nc, cfg.Lobby.SeekLifetime)
+	go lob.Run(ctx)
+
+	// =============================================== //
+	// Start Matchmaking
+	// =============================================== //
+	log.Println("main : Started : Initializing matchmaking")
+
+	queue := matchmaking.New(tickets, games, nc, cfg.Matchmaking.Interval)
+	go queue.Run(ctx)
+
+	// =============================================== //
+	// Start Challenges
+	// =============================================== //
+	log.Println("main : Started : Initializing challenges")
+
+	challenges := challenge.New(pending, games, nc, cfg.Challenge.Timeout)
+	go challenges.Run(ctx)
+
 	// =============================================== //
 	// Starting API
 	// =============================================== //
@@ -174,7 +296,7 @@ func run() error {
 		People: cfg.Database.Collections.People,
 	}
 
-	router := handlers.API(build, db, ab, nc, collections, cors, version)
+	router := handlers.API(build, db, games, lob, queue, challenges, invites, cfg.Takeback.Rated, ab, nc, collections, cors, version)
 
 	// =============================================== //
 	// Add File Server
@@ -123,7 +123,12 @@ update msg model =
         Recv m ->
             case m.t of
                 "fen" ->
-                    { model | state = fen2State m.m } |> withNoCmd
+                    case Decode.decodeString (field "fen" Decode.string) m.m of
+                        Ok fen ->
+                            { model | state = fen2State fen } |> withNoCmd
+
+                        Err _ ->
+                            model |> withNoCmd
 
                 "join" ->
                     updatePlayer m.m model |> withNoCmd
@@ -2,6 +2,7 @@ package auth
 
 import (
 	"context"
+	"strconv"
 	"time"
 
 	"github.com/pkg/errors"
@@ -21,7 +22,8 @@ type CollectionConfiguration struct {
 type User struct {
 
 	// Non-authboss related field
-	Name string `json:"name"`
+	Name   string `json:"name"`
+	Rating int    `json:"rating"`
 
 	// Auth
 	Email    string `json:"email"`
@@ -216,9 +218,16 @@ func (u User) GetOAuth2Expiry() (expiry time.Time) { return u.OAuth2Expiry }
 
 // GetArbitrary from user
 func (u User) GetArbitrary() map[string]string {
-	return map[string]string{
+	values := map[string]string{
 		"name": u.Name,
 	}
+
+	// Ratings are only ever read, players can not set their own rating.
+	if u.Rating > 0 {
+		values["rating"] = strconv.Itoa(u.Rating)
+	}
+
+	return values
 }
 
 // Storer stores users in memory
@@ -0,0 +1,248 @@
+// Package challenge keeps the challenges players send each other. The
+// challenged player starts a game by accepting a challenge.
+package challenge
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"math/rand"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go"
+	"github.com/pkg/errors"
+	"github.com/schafer14/chess-serve/internal/chess"
+)
+
+var (
+	// ErrChallengeNotFound is returned for challenges that were answered,
+	// cancelled, expired or never sent.
+	ErrChallengeNotFound = errors.New("challenge not found")
+
+	// ErrSelfChallenge is returned when a player challenges themselves.
+	ErrSelfChallenge = errors.New("players can not challenge themselves")
+
+	// ErrNotChallenged is returned when a player answers a challenge that
+	// was sent to someone else.
+	ErrNotChallenged = errors.New("only the challenged player can answer a challenge")
+
+	// ErrNotChallenger is returned when a player cancels a challenge they
+	// did not send.
+	ErrNotChallenger = errors.New("only the challenger can cancel a challenge")
+)
+
+// Challenge is a player asking another player for a game with the given
+// settings. Color is the side the challenger plays: white, black or random.
+type Challenge struct {
+	Id               string             `json:"id" bson:"_id"`
+	ChallengerId     string             `json:"challengerId"`
+	ChallengerName   string             `json:"challengerName"`
+	ChallengerRating int                `json:"challengerRating"`
+	UserId           string             `json:"userId"`
+	UserName         string             `json:"userName"`
+	TimeControl      *chess.TimeControl `json:"timeControl"`
+	Variant          string             `json:"variant"`
+	Color            string             `json:"color"`
+	Rated            bool               `json:"rated"`
+	Sent             time.Time          `json:"sent"`
+	Expires          time.Time          `json:"expires"`
+}
+
+// Accepted tells the challenger which game their challenge started.
+type Accepted struct {
+	ChallengeId string `json:"challengeId"`
+	GameId      string `json:"gameId"`
+}
+
+// Challenges holds the open challenges in a store. Players are told about
+// challenges on their own subject, see Subject:
+//
+//	challenge  a challenge was sent to the player
+//	accepted   a challenge of the player was accepted and its game started
+//	declined   a challenge of the player was declined
+//	cancelled  a challenge to the player was cancelled
+//	expired    a challenge to or from the player expired
+type Challenges struct {
+	challenges Store
+	games      chess.GameStore
+	nc         *nats.Conn
+	timeout    time.Duration
+}
+
+// New creates the challenges of a store that expire after timeout.
+func New(challenges Store, games chess.GameStore, nc *nats.Conn, timeout time.Duration) *Challenges {
+	return &Challenges{
+		challenges: challenges,
+		games:      games,
+		nc:         nc,
+		timeout:    timeout,
+	}
+}
+
+// Subject is where a player is told about their challenges. Player ids are
+// encoded because they can be emails, which have dots.
+func Subject(playerId string) string {
+	return "challenges." + hex.EncodeToString([]byte(playerId))
+}
+
+// Pending lists the open challenges a player sent or was sent, oldest first.
+func (c *Challenges) Pending(ctx context.Context, playerId string) ([]Challenge, error) {
+	return c.challenges.Pending(ctx, playerId)
+}
+
+// Send challenges the user of a challenge on behalf of a player. The id,
+// challenger and times of the challenge are filled in.
+func (c *Challenges) Send(ctx context.Context, ch Challenge, p chess.Player, now time.Time) (Challenge, error) {
+	if ch.UserId == p.Id {
+		return ch, ErrSelfChallenge
+	}
+
+	ch.Id = uuid.New().String()
+	ch.ChallengerId = p.Id
+	ch.ChallengerName = p.Name
+	ch.ChallengerRating = p.Rating
+	ch.Sent = now
+	ch.Expires = now.Add(c.timeout)
+	if ch.Color == "" {
+		ch.Color = "random"
+	}
+
+	if err := c.challenges.Add(ctx, ch); err != nil {
+		return ch, errors.Wrap(err, "sending challenge")
+	}
+
+	c.publish(ch.UserId, "challenge", ch)
+	return ch, nil
+}
+
+// Accept starts the game of a challenge sent to the player. The challenge
+// is taken out first so that it can only be answered once, even on another
+// server. It is put back if the game can not be created.
+func (c *Challenges) Accept(ctx context.Context, id string, p chess.Player, now time.Time) (chess.Game, error) {
+	ch, err := c.take(ctx, id, func(ch Challenge) error {
+		if ch.UserId != p.Id {
+			return ErrNotChallenged
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	challenger := chess.Player{Id: ch.ChallengerId, Name: ch.ChallengerName, Rating: ch.ChallengerRating}
+	white, black := challenger, p
+	if ch.Color == "black" || (ch.Color == "random" && rand.Intn(2) == 0) {
+		white, black = p, challenger
+	}
+
+	opts := chess.Options{TimeControl: ch.TimeControl, Variant: ch.Variant, Rated: ch.Rated}
+	game, err := chess.StartGame(ctx, c.games, white, black, opts, now)
+	if err != nil {
+		if err := c.challenges.Add(ctx, ch); err != nil {
+			log.Printf("challenge : putting back challenge %v: %v", ch.Id, err)
+		}
+		return nil, errors.Wrap(err, "creating game")
+	}
+
+	c.publish(ch.ChallengerId, "accepted", Accepted{ChallengeId: ch.Id, GameId: game.ID()})
+	return game, nil
+}
+
+// Decline turns down a challenge sent to the player.
+func (c *Challenges) Decline(ctx context.Context, id string, playerId string) error {
+	ch, err := c.take(ctx, id, func(ch Challenge) error {
+		if ch.UserId != playerId {
+			return ErrNotChallenged
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	c.publish(ch.ChallengerId, "declined", ch)
+	return nil
+}
+
+// Cancel takes back a challenge the player sent.
+func (c *Challenges) Cancel(ctx context.Context, id string, playerId string) error {
+	ch, err := c.take(ctx, id, func(ch Challenge) error {
+		if ch.ChallengerId != playerId {
+			return ErrNotChallenger
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	c.publish(ch.UserId, "cancelled", ch)
+	return nil
+}
+
+// take removes a challenge if check allows it.
+func (c *Challenges) take(ctx context.Context, id string, check func(Challenge) error) (Challenge, error) {
+	ch, err := c.challenges.Get(ctx, id)
+	if err != nil {
+		return ch, err
+	}
+	if err := check(ch); err != nil {
+		return ch, err
+	}
+
+	return c.challenges.Take(ctx, id)
+}
+
+// Run removes expired challenges until the context is cancelled.
+func (c *Challenges) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := c.expire(ctx, now); err != nil {
+				log.Printf("challenge : %v", err)
+			}
+		}
+	}
+}
+
+// expire removes the challenges that were not answered in time and tells
+// both players. When several servers share the store only the one that
+// takes an expired challenge tells the players.
+func (c *Challenges) expire(ctx context.Context, now time.Time) error {
+	expired, err := c.challenges.Expired(ctx, now)
+	if err != nil {
+		return errors.Wrap(err, "expiring challenges")
+	}
+
+	for _, ch := range expired {
+		_, err := c.challenges.Take(ctx, ch.Id)
+		if err == ErrChallengeNotFound {
+			continue
+		}
+		if err != nil {
+			return errors.Wrap(err, "expiring challenges")
+		}
+		c.publish(ch.ChallengerId, "expired", ch)
+		c.publish(ch.UserId, "expired", ch)
+	}
+
+	return nil
+}
+
+// publish sends a json encoded event to a player.
+func (c *Challenges) publish(playerId string, event string, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("challenge : encoding %v event: %v", event, err)
+		return
+	}
+
+	c.nc.Publish(Subject(playerId)+"."+event, data)
+}
@@ -0,0 +1,188 @@
+package challenge
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/schafer14/chess-serve/internal/chess"
+)
+
+// failingGames is a game store that can not create games.
+type failingGames struct {
+	chess.GameStore
+}
+
+func (failingGames) Create(ctx context.Context, g chess.Game) error {
+	return errors.New("store is down")
+}
+
+// send challenges the player "user" on behalf of the player "challenger".
+func send(t *testing.T, c *Challenges, ch Challenge, now time.Time) Challenge {
+	t.Helper()
+
+	ch.UserId = "user"
+	ch, err := c.Send(context.Background(), ch, chess.Player{Id: "challenger"}, now)
+	if err != nil {
+		t.Fatalf("sending challenge: %v", err)
+	}
+
+	return ch
+}
+
+// pending lists the ids of the open challenges of a player.
+func pending(t *testing.T, c *Challenges, playerId string) []string {
+	t.Helper()
+
+	challenges, err := c.Pending(context.Background(), playerId)
+	if err != nil {
+		t.Fatalf("listing challenges: %v", err)
+	}
+
+	ids := []string{}
+	for _, ch := range challenges {
+		ids = append(ids, ch.Id)
+	}
+
+	return ids
+}
+
+func TestSendToSelf(t *testing.T) {
+	c := New(NewMemoryStore(), chess.NewMemoryStore(), nil, time.Minute)
+
+	_, err := c.Send(context.Background(), Challenge{UserId: "challenger"}, chess.Player{Id: "challenger"}, time.Now())
+	if err != ErrSelfChallenge {
+		t.Errorf("challenging yourself: got error %v, want %v", err, ErrSelfChallenge)
+	}
+}
+
+func TestAccept(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	c := New(NewMemoryStore(), chess.NewMemoryStore(), nil, time.Minute)
+	ch := send(t, c, Challenge{Color: "black"}, now)
+
+	tests := []struct {
+		name   string
+		id     string
+		player string
+		err    error
+	}{
+		{"accepting an unknown challenge", "unknown", "user", ErrChallengeNotFound},
+		{"accepting a challenge sent to someone else", ch.Id, "other", ErrNotChallenged},
+		{"accepting an own challenge", ch.Id, "challenger", ErrNotChallenged},
+	}
+
+	for _, tt := range tests {
+		if _, err := c.Accept(ctx, tt.id, chess.Player{Id: tt.player}, now); err != tt.err {
+			t.Errorf("%v: got error %v, want %v", tt.name, err, tt.err)
+		}
+	}
+
+	game, err := c.Accept(ctx, ch.Id, chess.Player{Id: "user"}, now)
+	if err != nil {
+		t.Fatalf("accepting challenge: %v", err)
+	}
+	if _, err := c.Accept(ctx, ch.Id, chess.Player{Id: "user"}, now); err != ErrChallengeNotFound {
+		t.Errorf("accepting an accepted challenge: got error %v, want %v", err, ErrChallengeNotFound)
+	}
+
+	var players struct {
+		WhiteId string `json:"whiteId"`
+		BlackId string `json:"blackId"`
+	}
+	data, err := json.Marshal(game)
+	if err != nil {
+		t.Fatalf("encoding game: %v", err)
+	}
+	if err := json.Unmarshal(data, &players); err != nil {
+		t.Fatalf("decoding game: %v", err)
+	}
+	if players.WhiteId != "user" || players.BlackId != "challenger" {
+		t.Errorf("got %v against %v, want the challenger playing black", players.WhiteId, players.BlackId)
+	}
+}
+
+func TestAcceptPutsBackChallenge(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	c := New(NewMemoryStore(), failingGames{chess.NewMemoryStore()}, nil, time.Minute)
+	ch := send(t, c, Challenge{}, now)
+
+	if _, err := c.Accept(ctx, ch.Id, chess.Player{Id: "user"}, now); err == nil {
+		t.Fatal("accepting challenge without a game store: got no error")
+	}
+	if got := pending(t, c, "user"); len(got) != 1 || got[0] != ch.Id {
+		t.Errorf("got challenges %v, want %v put back", got, ch.Id)
+	}
+}
+
+func TestDecline(t *testing.T) {
+	ctx := context.Background()
+	c := New(NewMemoryStore(), chess.NewMemoryStore(), nil, time.Minute)
+	ch := send(t, c, Challenge{}, time.Now())
+
+	tests := []struct {
+		name   string
+		player string
+		err    error
+	}{
+		{"declining as the challenger", "challenger", ErrNotChallenged},
+		{"declining as someone else", "other", ErrNotChallenged},
+		{"declining", "user", nil},
+		{"declining a declined challenge", "user", ErrChallengeNotFound},
+	}
+
+	for _, tt := range tests {
+		if err := c.Decline(ctx, ch.Id, tt.player); err != tt.err {
+			t.Errorf("%v: got error %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestCancel(t *testing.T) {
+	ctx := context.Background()
+	c := New(NewMemoryStore(), chess.NewMemoryStore(), nil, time.Minute)
+	ch := send(t, c, Challenge{}, time.Now())
+
+	tests := []struct {
+		name   string
+		player string
+		err    error
+	}{
+		{"cancelling as the challenged player", "user", ErrNotChallenger},
+		{"cancelling as someone else", "other", ErrNotChallenger},
+		{"cancelling", "challenger", nil},
+		{"cancelling a cancelled challenge", "challenger", ErrChallengeNotFound},
+	}
+
+	for _, tt := range tests {
+		if err := c.Cancel(ctx, ch.Id, tt.player); err != tt.err {
+			t.Errorf("%v: got error %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestExpire(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	c := New(NewMemoryStore(), chess.NewMemoryStore(), nil, time.Minute)
+
+	old := send(t, c, Challenge{}, now.Add(-time.Minute))
+	fresh := send(t, c, Challenge{}, now.Add(-time.Minute+time.Second))
+
+	if err := c.expire(ctx, now); err != nil {
+		t.Fatalf("expiring challenges: %v", err)
+	}
+
+	for _, player := range []string{"challenger", "user"} {
+		if got := pending(t, c, player); len(got) != 1 || got[0] != fresh.Id {
+			t.Errorf("got challenges %v of %v after %v expired, want only %v", got, player, old.Id, fresh.Id)
+		}
+	}
+	if _, err := c.Accept(ctx, old.Id, chess.Player{Id: "user"}, now); err != ErrChallengeNotFound {
+		t.Errorf("accepting an expired challenge: got error %v, want %v", err, ErrChallengeNotFound)
+	}
+}
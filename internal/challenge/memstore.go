@@ -0,0 +1,91 @@
+package challenge
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps challenges in memory. Challenges are lost when the
+// process stops and can only be answered on the server that keeps them.
+type MemoryStore struct {
+	mu         sync.Mutex
+	challenges map[string]Challenge
+}
+
+var _ Store = &MemoryStore{}
+
+// NewMemoryStore creates an empty store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{challenges: map[string]Challenge{}}
+}
+
+// Add stores a new challenge.
+func (s *MemoryStore) Add(ctx context.Context, ch Challenge) error {
+	s.mu.Lock()
+	s.challenges[ch.Id] = ch
+	s.mu.Unlock()
+
+	return nil
+}
+
+// Get loads the challenge with an id.
+func (s *MemoryStore) Get(ctx context.Context, id string) (Challenge, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ch, ok := s.challenges[id]
+	if !ok {
+		return ch, ErrChallengeNotFound
+	}
+
+	return ch, nil
+}
+
+// Pending loads the challenges a player sent or was sent, oldest first.
+func (s *MemoryStore) Pending(ctx context.Context, playerId string) ([]Challenge, error) {
+	s.mu.Lock()
+	pending := []Challenge{}
+	for _, ch := range s.challenges {
+		if ch.ChallengerId == playerId || ch.UserId == playerId {
+			pending = append(pending, ch)
+		}
+	}
+	s.mu.Unlock()
+
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].Sent.Before(pending[j].Sent)
+	})
+
+	return pending, nil
+}
+
+// Expired loads the challenges that have expired at a time.
+func (s *MemoryStore) Expired(ctx context.Context, now time.Time) ([]Challenge, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var expired []Challenge
+	for _, ch := range s.challenges {
+		if !now.Before(ch.Expires) {
+			expired = append(expired, ch)
+		}
+	}
+
+	return expired, nil
+}
+
+// Take removes the challenge with an id and returns it.
+func (s *MemoryStore) Take(ctx context.Context, id string) (Challenge, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ch, ok := s.challenges[id]
+	if !ok {
+		return ch, ErrChallengeNotFound
+	}
+	delete(s.challenges, id)
+
+	return ch, nil
+}
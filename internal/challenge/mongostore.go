@@ -0,0 +1,105 @@
+package challenge
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoStore keeps challenges in a mongo collection.
+type MongoStore struct {
+	coll *mongo.Collection
+}
+
+var _ Store = &MongoStore{}
+
+// NewMongoStore creates a store for the challenges in a collection.
+func NewMongoStore(coll *mongo.Collection) *MongoStore {
+	return &MongoStore{coll: coll}
+}
+
+// Add stores a new challenge.
+func (s *MongoStore) Add(ctx context.Context, ch Challenge) error {
+	if _, err := s.coll.InsertOne(ctx, ch); err != nil {
+		return errors.Wrap(err, "inserting challenge")
+	}
+
+	return nil
+}
+
+// Get loads the challenge with an id.
+func (s *MongoStore) Get(ctx context.Context, id string) (Challenge, error) {
+	var ch Challenge
+	err := s.coll.FindOne(ctx, byId(id)).Decode(&ch)
+	if err == mongo.ErrNoDocuments {
+		return ch, ErrChallengeNotFound
+	}
+
+	return ch, errors.Wrap(err, "retrieving challenge")
+}
+
+// Pending loads the challenges a player sent or was sent, oldest first.
+func (s *MongoStore) Pending(ctx context.Context, playerId string) ([]Challenge, error) {
+	filter := bson.D{primitive.E{Key: "$or", Value: bson.A{
+		bson.D{primitive.E{Key: "challengerid", Value: playerId}},
+		bson.D{primitive.E{Key: "userid", Value: playerId}},
+	}}}
+	opts := options.Find().SetSort(bson.D{primitive.E{Key: "sent", Value: 1}})
+
+	return s.find(ctx, filter, opts)
+}
+
+// Expired loads the challenges that have expired at a time.
+func (s *MongoStore) Expired(ctx context.Context, now time.Time) ([]Challenge, error) {
+	filter := bson.D{primitive.E{Key: "expires", Value: bson.D{primitive.E{Key: "$lte", Value: now}}}}
+
+	return s.find(ctx, filter, options.Find())
+}
+
+// Take removes the challenge with an id and returns it. Mongo deletes the
+// challenge and returns it in one step so only one caller gets it.
+func (s *MongoStore) Take(ctx context.Context, id string) (Challenge, error) {
+	var ch Challenge
+	err := s.coll.FindOneAndDelete(ctx, byId(id)).Decode(&ch)
+	if err == mongo.ErrNoDocuments {
+		return ch, ErrChallengeNotFound
+	}
+
+	return ch, errors.Wrap(err, "taking challenge")
+}
+
+// EnsureIndexes creates the indexes challenges are found by.
+func (s *MongoStore) EnsureIndexes(ctx context.Context) error {
+	_, err := s.coll.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{primitive.E{Key: "challengerid", Value: 1}}},
+		{Keys: bson.D{primitive.E{Key: "userid", Value: 1}}},
+		{Keys: bson.D{primitive.E{Key: "expires", Value: 1}}},
+	})
+
+	return errors.Wrap(err, "creating challenge indexes")
+}
+
+// find loads the challenges matching a filter.
+func (s *MongoStore) find(ctx context.Context, filter bson.D, opts *options.FindOptions) ([]Challenge, error) {
+	cur, err := s.coll.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "finding challenges")
+	}
+
+	challenges := []Challenge{}
+	if err := cur.All(ctx, &challenges); err != nil {
+		return nil, errors.Wrap(err, "decoding challenges")
+	}
+
+	return challenges, nil
+}
+
+// byId matches the challenge with an id.
+func byId(id string) bson.D {
+	return bson.D{primitive.E{Key: "_id", Value: id}}
+}
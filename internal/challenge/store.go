@@ -0,0 +1,29 @@
+package challenge
+
+import (
+	"context"
+	"time"
+)
+
+// Store keeps the open challenges. A store other than the memory store can
+// be shared by several servers so that a challenge sent to one of them can
+// be answered on any of them.
+type Store interface {
+	// Add stores a new challenge.
+	Add(ctx context.Context, ch Challenge) error
+
+	// Get loads the challenge with an id. It fails with
+	// ErrChallengeNotFound if there is none.
+	Get(ctx context.Context, id string) (Challenge, error)
+
+	// Pending loads the challenges a player sent or was sent, oldest first.
+	Pending(ctx context.Context, playerId string) ([]Challenge, error)
+
+	// Expired loads the challenges that have expired at a time.
+	Expired(ctx context.Context, now time.Time) ([]Challenge, error)
+
+	// Take removes the challenge with an id and returns it. Only one caller
+	// can take a challenge, it fails with ErrChallengeNotFound for everyone
+	// else.
+	Take(ctx context.Context, id string) (Challenge, error)
+}
@@ -0,0 +1,130 @@
+package chess
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/schafer14/MtM/common"
+)
+
+const (
+	TerminationResignation Termination = "resignation"
+	TerminationAgreement   Termination = "agreement"
+	TerminationAborted     Termination = "aborted"
+)
+
+var (
+	// ErrNoDrawOffer is returned when answering a draw offer that was never
+	// made by the opponent.
+	ErrNoDrawOffer = errors.New("no draw has been offered")
+
+	// ErrCannotAbort is returned when aborting a game after both players
+	// have moved.
+	ErrCannotAbort = errors.New("game can only be aborted before both players have moved")
+)
+
+// colorName is the name used for a color in game documents and events.
+func colorName(color uint) string {
+	if color == common.White {
+		return "white"
+	}
+	return "black"
+}
+
+// opponent returns the other color.
+func opponent(color uint) uint {
+	if color == common.White {
+		return common.Black
+	}
+	return common.White
+}
+
+// Resign ends the game as a loss for the player.
+func (g *game) Resign(playerId string, now time.Time) error {
+	color, err := g.activeColor(playerId)
+	if err != nil {
+		return err
+	}
+
+	outcome := Outcome{winFor(opponent(color)), TerminationResignation}
+	g.record(Event{Type: EventResigned, Time: now, ActorId: playerId, Color: colorName(color), Outcome: &outcome})
+
+	return nil
+}
+
+// OfferDraw offers the opponent a draw. The offer stands until the opponent
+// answers it or makes a move.
+func (g *game) OfferDraw(playerId string, now time.Time) error {
+	color, err := g.activeColor(playerId)
+	if err != nil {
+		return err
+	}
+
+	g.record(Event{Type: EventDrawOffered, Time: now, ActorId: playerId, Color: colorName(color)})
+
+	return nil
+}
+
+// AcceptDraw ends the game in a draw if the opponent has offered one.
+func (g *game) AcceptDraw(playerId string, now time.Time) error {
+	color, err := g.activeColor(playerId)
+	if err != nil {
+		return err
+	}
+	if g.DrawOffer != colorName(opponent(color)) {
+		return ErrNoDrawOffer
+	}
+
+	outcome := Outcome{ResultDraw, TerminationAgreement}
+	g.record(Event{Type: EventDrawAccepted, Time: now, ActorId: playerId, Color: colorName(color), Outcome: &outcome})
+
+	return nil
+}
+
+// DeclineDraw rejects the opponents draw offer.
+func (g *game) DeclineDraw(playerId string, now time.Time) error {
+	color, err := g.activeColor(playerId)
+	if err != nil {
+		return err
+	}
+	if g.DrawOffer != colorName(opponent(color)) {
+		return ErrNoDrawOffer
+	}
+
+	g.record(Event{Type: EventDrawDeclined, Time: now, ActorId: playerId, Color: colorName(color)})
+
+	return nil
+}
+
+// Abort cancels the game without a result. This is only possible before
+// both players have made their first move.
+func (g *game) Abort(playerId string, now time.Time) error {
+	if g.IsOver() {
+		return ErrGameOver
+	}
+	color, err := g.colorOf(playerId)
+	if err != nil {
+		return err
+	}
+	if len(g.Moves) >= 2 {
+		return ErrCannotAbort
+	}
+
+	outcome := Outcome{ResultNone, TerminationAborted}
+	g.record(Event{Type: EventAborted, Time: now, ActorId: playerId, Color: colorName(color), Outcome: &outcome})
+
+	return nil
+}
+
+// activeColor finds the color of a player for actions that need a game that
+// is being played.
+func (g *game) activeColor(playerId string) (uint, error) {
+	if g.IsOver() {
+		return 0, ErrGameOver
+	}
+	if g.Status == StatusInitiating {
+		return 0, ErrNotStarted
+	}
+
+	return g.colorOf(playerId)
+}
@@ -0,0 +1,156 @@
+package chess
+
+import (
+	"math/bits"
+	"math/rand"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/schafer14/MtM/board"
+	"github.com/schafer14/MtM/common"
+)
+
+// ErrInvalidChess960Position is returned for Chess960 start position
+// numbers outside of 0 to 959.
+var ErrInvalidChess960Position = errors.New("chess960 positions are numbered from 0 to 959")
+
+// fischerRandom is Chess960, where the pieces on the first rank are shuffled
+// and castling puts the king and rook on the same squares as in standard
+// chess.
+type fischerRandom struct {
+	standard
+}
+
+func (fischerRandom) Name() string {
+	return VariantChess960
+}
+
+// start picks the numbered start position, or a random one when there is
+// no number. A FEN can also be given to start from a later position.
+func (fischerRandom) start(opts Options) (string, error) {
+	if opts.Fen != "" {
+		return opts.Fen, nil
+	}
+
+	n := rand.Intn(960)
+	if opts.Chess960Position != nil {
+		n = *opts.Chess960Position
+	}
+
+	return chess960Fen(n)
+}
+
+func (fischerRandom) chess960() bool {
+	return true
+}
+
+// chess960Knights are the squares the knights go on out of the five squares
+// left after placing the bishops and queen, in the order of the standard
+// numbering.
+var chess960Knights = [10][2]int{
+	{0, 1}, {0, 2}, {0, 3}, {0, 4}, {1, 2}, {1, 3}, {1, 4}, {2, 3}, {2, 4}, {3, 4},
+}
+
+// chess960Fen writes the FEN of one of the 960 start positions using the
+// standard numbering, where position 518 is the standard starting position.
+func chess960Fen(n int) (string, error) {
+	if n < 0 || n > 959 {
+		return "", ErrInvalidChess960Position
+	}
+
+	var rank [8]byte
+
+	// The bishops go on opposite colored squares, the light squared bishop on
+	// the b, d, f or h file and the dark squared one on the a, c, e or g file.
+	rank[n%4*2+1] = 'B'
+	n /= 4
+	rank[n%4*2] = 'B'
+	n /= 4
+
+	empty := func() []int {
+		var files []int
+		for file, piece := range rank {
+			if piece == 0 {
+				files = append(files, file)
+			}
+		}
+		return files
+	}
+
+	rank[empty()[n%6]] = 'Q'
+	n /= 6
+
+	files := empty()
+	rank[files[chess960Knights[n][0]]] = 'N'
+	rank[files[chess960Knights[n][1]]] = 'N'
+
+	// The king goes between the rooks on the three squares that are left.
+	for i, file := range empty() {
+		rank[file] = "RKR"[i]
+	}
+
+	white := string(rank[:])
+	black := strings.ToLower(white)
+
+	return black + "/pppppppp/8/8/8/8/PPPPPPPP/" + white + " w KQkq - 0 1", nil
+}
+
+// parseChess960Castling reads the castling rights of a Chess960 position in
+// either X-FEN or Shredder-FEN. K and Q stand for the outermost rook on that
+// side of the king and a file letter for the rook on that file.
+func parseChess960Castling(b board.Board, castling string) (uint64, error) {
+	var rooks uint64
+	if castling == "-" {
+		return rooks, nil
+	}
+
+	for _, right := range castling {
+		color := common.White
+		if right >= 'a' && right <= 'z' {
+			color = common.Black
+		}
+
+		own := b.Colors[color]
+		kings := own & b.Pieces[common.King] & backRank(color)
+		if kings == 0 {
+			return 0, errors.Wrapf(ErrInvalidFen, "castling right %c needs the king on its first rank", right)
+		}
+		king := uint(bits.TrailingZeros64(kings))
+		candidates := own & b.Pieces[common.Rook] & backRank(color)
+
+		var rook uint64
+		switch letter := strings.ToUpper(string(right))[0]; {
+		case letter == 'K':
+			if above := candidates >> (king + 1) << (king + 1); above != 0 {
+				rook = 1 << uint(63-bits.LeadingZeros64(above))
+			}
+		case letter == 'Q':
+			if below := candidates & (1<<king - 1); below != 0 {
+				rook = below & -below
+			}
+		case letter >= 'A' && letter <= 'H':
+			rook = candidates & (1 << (king/8*8 + uint(letter-'A')))
+		default:
+			return 0, errors.Wrapf(ErrInvalidFen, "invalid castling rights %q", castling)
+		}
+
+		if rook == 0 {
+			return 0, errors.Wrapf(ErrInvalidFen, "castling right %c needs a rook to castle with", right)
+		}
+		if rooks&rook != 0 {
+			return 0, errors.Wrapf(ErrInvalidFen, "invalid castling rights %q", castling)
+		}
+		rooks |= rook
+	}
+
+	// Each side of the king can only have one rook to castle with.
+	for _, color := range []uint{common.White, common.Black} {
+		king := uint(bits.TrailingZeros64(b.Colors[color] & b.Pieces[common.King]))
+		own := rooks & backRank(color)
+		if bits.OnesCount64(own>>king) > 1 || bits.OnesCount64(own&(1<<king-1)) > 1 {
+			return 0, errors.Wrapf(ErrInvalidFen, "invalid castling rights %q", castling)
+		}
+	}
+
+	return rooks, nil
+}
@@ -0,0 +1,131 @@
+package chess
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestChess960Numbering(t *testing.T) {
+	tests := []struct {
+		n     int
+		white string
+		err   error
+	}{
+		{0, "BBQNNRKR", nil},
+		{518, "RNBQKBNR", nil},
+		{959, "RKRNNQBB", nil},
+		{-1, "", ErrInvalidChess960Position},
+		{960, "", ErrInvalidChess960Position},
+	}
+
+	for _, tt := range tests {
+		fen, err := chess960Fen(tt.n)
+		if err != tt.err {
+			t.Errorf("position %d: got error %v, want %v", tt.n, err, tt.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		want := strings.ToLower(tt.white) + "/pppppppp/8/8/8/8/PPPPPPPP/" + tt.white + " w KQkq - 0 1"
+		if fen != want {
+			t.Errorf("position %d: got %v, want %v", tt.n, fen, want)
+		}
+	}
+
+	n := 960
+	_, err := NewGame(primitive.NewObjectID(), time.Now(), Player{Id: "white"}, Options{Variant: VariantChess960, Chess960Position: &n})
+	if err != ErrInvalidChess960Position {
+		t.Errorf("creating game at position %d: got error %v, want %v", n, err, ErrInvalidChess960Position)
+	}
+}
+
+func TestChess960Castling(t *testing.T) {
+	tests := []struct {
+		name  string
+		fen   string
+		move  string
+		after string
+	}{
+		{"king takes rook", "4k3/8/8/8/8/8/8/RK6 w Q - 0 1", "b1a1", "2KR4 b"},
+		{"king to its square", "4k3/8/8/8/8/8/8/RK6 w Q - 0 1", "O-O-O", "2KR4 b"},
+		{"king next to its square", "4k3/8/8/8/8/8/8/RK6 w Q - 0 1", "b1c1", "R1K5 b"},
+		{"king already on its square", "4k3/8/8/8/8/8/8/6KR w K - 0 1", "g1h1", "5RK1 b"},
+		{"king already on its square in san", "4k3/8/8/8/8/8/8/6KR w K - 0 1", "O-O", "5RK1 b"},
+		{"rook already on its square", "4k3/8/8/8/8/8/8/3RK3 w Q - 0 1", "e1d1", "2KR4 b"},
+		{"rook already on its square by the king's square", "4k3/8/8/8/8/8/8/3RK3 w Q - 0 1", "e1c1", "2KR4 b"},
+		{"black castling", "rk6/8/8/8/8/8/8/4K3 b q - 0 1", "b8a8", "2kr4/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := startVariant(t, VariantChess960, tt.fen)
+			play(t, g, tt.move)
+
+			if fen := g.Fen(); !strings.Contains(fen, tt.after) || strings.Fields(fen)[2] != "-" {
+				t.Errorf("got %v, want %v without castling rights", fen, tt.after)
+			}
+		})
+	}
+}
+
+func TestChess960CastlingRights(t *testing.T) {
+	standard := "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+	innerRooks := "rk3r1r/pppppppp/8/8/8/8/PPPPPPPP/RK3R1R w FQfq - 0 1"
+
+	tests := []struct {
+		name   string
+		fen    string
+		moves  string
+		rights string
+	}{
+		{"start", standard, "", "KQkq"},
+		{"king side rook moved", standard, "h2h4 a7a5 h1h3", "Qkq"},
+		{"queen side rook moved", standard, "h2h4 a7a5 h1h3 a8a6", "Qk"},
+		{"king moved", standard, "e2e4 e7e5 e1e2", "kq"},
+		{"inner rook", innerRooks, "", "FQfq"},
+		{"outer rook moved", innerRooks, "h2h4 h7h5 h1h3", "KQfq"},
+		{"rook captured", "rk5r/8/8/8/8/8/8/RK5R w KQkq - 0 1", "h1h8", "Qq"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := startVariant(t, VariantChess960, tt.fen)
+			play(t, g, strings.Fields(tt.moves)...)
+
+			if rights := strings.Fields(g.Fen())[2]; rights != tt.rights {
+				t.Errorf("got castling rights %v, want %v", rights, tt.rights)
+			}
+		})
+	}
+}
+
+func TestChess960CastlingFen(t *testing.T) {
+	tests := []struct {
+		name string
+		fen  string
+		err  string
+	}{
+		{"x-fen", "rk5r/8/8/8/8/8/8/RK5R w KQkq - 0 1", ""},
+		{"shredder-fen", "rk5r/8/8/8/8/8/8/RK5R w HAha - 0 1", ""},
+		{"without a rook", "rk6/8/8/8/8/8/8/RK5R w KQkq - 0 1", "castling right k needs a rook to castle with"},
+		{"two rooks on one side", "1k6/8/8/8/8/8/8/RK3R1R w FH - 0 1", "invalid castling rights"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseFen(tt.fen, fischerRandom{})
+			if tt.err == "" && err != nil {
+				t.Errorf("got error %v, want none", err)
+			}
+			if tt.err != "" && (errors.Cause(err) != ErrInvalidFen || !strings.Contains(err.Error(), tt.err)) {
+				t.Errorf("got error %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
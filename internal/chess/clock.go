@@ -0,0 +1,162 @@
+package chess
+
+import (
+	"math/bits"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/schafer14/MtM/board"
+	"github.com/schafer14/MtM/common"
+)
+
+const (
+	TerminationTimeout                     Termination = "timeout"
+	TerminationTimeoutInsufficientMaterial Termination = "timeout vs insufficient material"
+)
+
+// ErrFlagFall is returned when a player tries to move after their time has
+// run out. The game is finished when this error is returned.
+var ErrFlagFall = errors.New("time has run out")
+
+// TimeControl is the time each player gets for the game. All values are in
+// seconds. After each move a player either gets the increment added to their
+// clock (Fischer) or gets back the time they used up to the delay
+// (Bronstein).
+type TimeControl struct {
+	Base      int `json:"base"`
+	Increment int `json:"increment,omitempty"`
+	Delay     int `json:"delay,omitempty"`
+}
+
+// Clock is the time each player has left in milliseconds.
+type Clock struct {
+	White   int64  `json:"white"`
+	Black   int64  `json:"black"`
+	Running string `json:"running,omitempty"`
+}
+
+// charge takes the time spent on a move off a players clock and gives back
+// the increment or delay.
+func (tc TimeControl) charge(left time.Duration, spent time.Duration) time.Duration {
+	left -= spent
+	if tc.Increment > 0 {
+		left += time.Duration(tc.Increment) * time.Second
+	}
+	if tc.Delay > 0 {
+		delay := time.Duration(tc.Delay) * time.Second
+		if spent < delay {
+			delay = spent
+		}
+		left += delay
+	}
+
+	return left
+}
+
+// clocksRunning checks if time is being taken off the clock of the side to
+// move. Clocks start once both players have made their first move.
+func (g *game) clocksRunning() bool {
+	return g.TimeControl != nil && g.Status == StatusInProgress && len(g.MoveTimes) >= 2
+}
+
+// timeLeft works out how much time each color has left at a moment in the
+// game. The side to move is charged for the time since the last move.
+func (g *game) timeLeft(turn uint, now time.Time) [2]time.Duration {
+	base := time.Duration(g.TimeControl.Base) * time.Second
+	left := [2]time.Duration{base, base}
+
+	// Replay the clock backwards from the side to move so games that did not
+	// start with white to move are charged correctly.
+	color := turn
+	for i := len(g.MoveTimes) - 1; i >= 2; i-- {
+		color = opponent(color)
+		spent := g.MoveTimes[i].Sub(g.MoveTimes[i-1])
+		left[color] = g.TimeControl.charge(left[color], spent)
+	}
+
+	if g.clocksRunning() {
+		left[turn] -= now.Sub(g.MoveTimes[len(g.MoveTimes)-1])
+	}
+
+	return left
+}
+
+// moveClocks works out the time each player had left right after making each
+// move of the game.
+func (g *game) moveClocks() []time.Duration {
+	base := time.Duration(g.TimeControl.Base) * time.Second
+	left := [2]time.Duration{base, base}
+
+	color := g.start().pos.board.Turn
+	clocks := make([]time.Duration, len(g.MoveTimes))
+	for i := range g.MoveTimes {
+		if i >= 2 {
+			left[color] = g.TimeControl.charge(left[color], g.MoveTimes[i].Sub(g.MoveTimes[i-1]))
+		}
+		clocks[i] = left[color]
+		color = opponent(color)
+	}
+
+	return clocks
+}
+
+// Clock returns the time left for both players. Untimed games have no clock.
+func (g *game) Clock(now time.Time) *Clock {
+	if g.TimeControl == nil {
+		return nil
+	}
+
+	turn := g.history().pos.board.Turn
+	left := g.timeLeft(turn, now)
+
+	c := Clock{
+		White: left[common.White].Milliseconds(),
+		Black: left[common.Black].Milliseconds(),
+	}
+	if c.White < 0 {
+		c.White = 0
+	}
+	if c.Black < 0 {
+		c.Black = 0
+	}
+	if g.clocksRunning() {
+		c.Running = colorName(turn)
+	}
+
+	return &c
+}
+
+// flag ends the game if the side to move has run out of time. When the
+// opponent could not possibly win the game is drawn instead.
+func (g *game) flag(p position, now time.Time) bool {
+	b := p.board
+	if !g.clocksRunning() || g.timeLeft(b.Turn, now)[b.Turn] > 0 {
+		return false
+	}
+
+	outcome := Outcome{winFor(b.Opp()), TerminationTimeout}
+	if !p.variant.canWin(b, b.Opp()) {
+		outcome = Outcome{ResultDraw, TerminationTimeoutInsufficientMaterial}
+	}
+	g.record(Event{Type: EventFlagged, Time: now, Outcome: &outcome})
+
+	return true
+}
+
+// cannotMate checks if a color does not have the material to checkmate.
+// That is a lone king, a king and a single minor piece or a king and bishops
+// that all stand on the same color.
+func cannotMate(b board.Board, color uint) bool {
+	own := b.Colors[color]
+	if own&(b.Pieces[common.Pawn]|b.Pieces[common.Rook]|b.Pieces[common.Queen]) != 0 {
+		return false
+	}
+
+	knights := own & b.Pieces[common.Knight]
+	bishops := own & b.Pieces[common.Bishop]
+	if bits.OnesCount64(knights|bishops) <= 1 {
+		return true
+	}
+
+	return knights == 0 && (bishops&lightSquares == 0 || bishops&^lightSquares == 0)
+}
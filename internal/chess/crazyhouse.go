@@ -0,0 +1,281 @@
+package chess
+
+import (
+	"math/bits"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+	"github.com/schafer14/MtM/board"
+	"github.com/schafer14/MtM/common"
+	"github.com/schafer14/MtM/move"
+)
+
+var (
+	// ErrPawnDrop is returned for pawns dropped on the first or last rank.
+	ErrPawnDrop = errors.New("pawns can not be dropped on the first or last rank")
+
+	// ErrNotInPocket is returned for drops of a piece the player does not
+	// have in their pocket.
+	ErrNotInPocket = errors.New("that piece is not in your pocket")
+)
+
+// dropLetters are the letters of the pieces that can be dropped.
+const dropLetters = "PNBRQ"
+
+// dropPattern matches drops like N@f3. Pawn drops can leave out the piece
+// as in @e4.
+var dropPattern = regexp.MustCompile(`^([PNBRQpnbrq])?@([a-h][1-8])[+#]?[!?]*$`)
+
+// Pockets are the captured pieces each side can drop back on the board in
+// Crazyhouse.
+type Pockets struct {
+	White Pocket `json:"white"`
+	Black Pocket `json:"black"`
+}
+
+// Pocket counts the pieces of each type a side can drop.
+type Pocket struct {
+	Pawn   int `json:"pawn"`
+	Knight int `json:"knight"`
+	Bishop int `json:"bishop"`
+	Rook   int `json:"rook"`
+	Queen  int `json:"queen"`
+}
+
+// Pockets returns the pieces each side can drop in Crazyhouse games. Other
+// variants have no pockets.
+func (g *game) Pockets() *Pockets {
+	if !g.variant().pockets() {
+		return nil
+	}
+
+	p := g.history().pos
+	pocket := func(color uint) Pocket {
+		counts := p.pockets[color]
+		return Pocket{counts[common.Pawn], counts[common.Knight], counts[common.Bishop], counts[common.Rook], counts[common.Queen]}
+	}
+
+	return &Pockets{White: pocket(common.White), Black: pocket(common.Black)}
+}
+
+// crazyhouse is standard chess where captured pieces join the army of the
+// side that captured them and can be dropped back on the board as a move.
+type crazyhouse struct {
+	standard
+}
+
+func (crazyhouse) Name() string {
+	return VariantCrazyhouse
+}
+
+func (crazyhouse) pockets() bool {
+	return true
+}
+
+// insufficientMaterial is never the case as pieces in a pocket can always
+// be dropped.
+func (crazyhouse) insufficientMaterial(b board.Board) bool {
+	return false
+}
+
+func (crazyhouse) canWin(b board.Board, color uint) bool {
+	return true
+}
+
+// isDrop checks if a move drops a piece from a pocket. Drops are kept as
+// moves that start and end on the square the piece is dropped on.
+func isDrop(m move.Move32) bool {
+	return m.Src() == m.Dest()
+}
+
+// drops lists the legal drops of the side to move.
+func (p position) drops() []move.Move32 {
+	if !p.variant.pockets() {
+		return nil
+	}
+
+	b := p.board
+	color := b.Turn
+	empty := ^(b.Colors[common.White] | b.Colors[common.Black])
+	inCheck := b.IsInCheck(color)
+
+	var moves []move.Move32
+	for piece := common.Pawn; piece < common.King; piece++ {
+		if p.pockets[color][piece] == 0 {
+			continue
+		}
+
+		squares := empty
+		if piece == common.Pawn {
+			squares &^= common.Row1 | common.Row8
+		}
+		for ; squares != 0; squares &= squares - 1 {
+			square := uint(bits.TrailingZeros64(squares))
+			m := move.New(piece, square, square)
+
+			// Dropping a piece can only matter to the king when it blocks a
+			// check.
+			if inCheck {
+				test := p
+				test.play(m)
+				if test.board.IsInCheck(color) {
+					continue
+				}
+			}
+			moves = append(moves, m)
+		}
+	}
+
+	return moves
+}
+
+// pocket keeps the pockets up to date for a move. Captured pieces go to the
+// pocket of the side capturing them, promoted pieces going back as pawns. A
+// dropped piece is taken out of the pocket and put on the board, so playing
+// the drop on the board only passes the turn.
+func (p *position) pocket(m move.Move32) {
+	color := p.board.Turn
+
+	if isDrop(m) {
+		p.pockets[color][m.Piece()]--
+		p.board.Pieces[m.Piece()] |= 1 << m.Dest()
+		p.board.Colors[color] |= 1 << m.Dest()
+		return
+	}
+
+	if m.IsCap() {
+		piece, square := m.Capture()
+		if p.promoted&(1<<square) != 0 {
+			piece = common.Pawn
+		}
+		p.pockets[color][piece]++
+		p.promoted &^= 1 << square
+	}
+	if p.promoted&(1<<m.Src()) != 0 {
+		p.promoted ^= 1<<m.Src() | 1<<m.Dest()
+	}
+	if isPromo, _ := m.Promotion(); isPromo {
+		p.promoted |= 1 << m.Dest()
+	}
+}
+
+// parseDrop finds the legal drop of a piece onto a square.
+func parseDrop(p position, piece string, square string) (move.Move32, error) {
+	kind := common.Pawn
+	if piece != "" {
+		kind = uint(strings.Index(dropLetters, strings.ToUpper(piece)))
+	}
+	dest := squareNum(square)
+
+	for _, m := range p.drops() {
+		if m.Piece() == kind && m.Dest() == dest {
+			return m, nil
+		}
+	}
+
+	switch {
+	case !p.variant.pockets():
+		return 0, ErrIllegalMove
+	case kind == common.Pawn && (1<<dest)&(common.Row1|common.Row8) != 0:
+		return 0, ErrPawnDrop
+	case p.pockets[p.board.Turn][kind] == 0:
+		return 0, ErrNotInPocket
+	}
+
+	return 0, ErrIllegalMove
+}
+
+// dropString writes a drop like N@f3.
+func dropString(m move.Move32) string {
+	return dropLetters[m.Piece():m.Piece()+1] + "@" + squareName(m.Dest())
+}
+
+// pocketPlacement adds the pockets and the markers of promoted pieces to the
+// piece placement of a FEN, as in N~ for a knight that was a pawn and
+// [Qnp] for the pieces in the pockets.
+func (p position) pocketPlacement(placement string) string {
+	var sb strings.Builder
+
+	square := uint(56)
+	for _, c := range placement {
+		sb.WriteRune(c)
+		switch {
+		case c == '/':
+			square -= 16
+		case c >= '1' && c <= '8':
+			square += uint(c - '0')
+		default:
+			if p.promoted&(1<<square) != 0 {
+				sb.WriteByte('~')
+			}
+			square++
+		}
+	}
+
+	sb.WriteByte('[')
+	for _, color := range []uint{common.White, common.Black} {
+		for piece := int(common.Queen); piece >= int(common.Pawn); piece-- {
+			letter := dropLetters[piece : piece+1]
+			if color == common.Black {
+				letter = strings.ToLower(letter)
+			}
+			sb.WriteString(strings.Repeat(letter, p.pockets[color][piece]))
+		}
+	}
+	sb.WriteByte(']')
+
+	return sb.String()
+}
+
+// parsePocketPlacement splits the pockets and promoted pieces off the piece
+// placement of a Crazyhouse FEN.
+func parsePocketPlacement(placement string) (string, [2][5]int, uint64, error) {
+	var pockets [2][5]int
+	var promoted uint64
+
+	if i := strings.IndexByte(placement, '['); i >= 0 {
+		if !strings.HasSuffix(placement, "]") {
+			return "", pockets, 0, errors.Wrap(ErrInvalidFen, "pockets must be closed with ]")
+		}
+		for _, c := range placement[i+1 : len(placement)-1] {
+			color := common.White
+			if c >= 'a' && c <= 'z' {
+				color = common.Black
+			}
+			piece := strings.IndexRune(dropLetters, unicode.ToUpper(c))
+			if piece < 0 {
+				return "", pockets, 0, errors.Wrapf(ErrInvalidFen, "%q can not be in a pocket", c)
+			}
+			pockets[color][piece]++
+		}
+		placement = placement[:i]
+	}
+
+	var sb strings.Builder
+	square, afterPiece := uint(56), false
+	for _, c := range placement {
+		switch {
+		case c == '~':
+			if !afterPiece {
+				return "", pockets, 0, errors.Wrap(ErrInvalidFen, "~ must follow a piece")
+			}
+			promoted |= 1 << (square - 1)
+			afterPiece = false
+			continue
+		case c == '/':
+			square -= 16
+			afterPiece = false
+		case c >= '1' && c <= '8':
+			square += uint(c - '0')
+			afterPiece = false
+		default:
+			square++
+			afterPiece = true
+		}
+		sb.WriteRune(c)
+	}
+
+	return sb.String(), pockets, promoted, nil
+}
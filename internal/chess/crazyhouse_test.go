@@ -0,0 +1,103 @@
+package chess
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestCrazyhousePockets(t *testing.T) {
+	tests := []struct {
+		name    string
+		fen     string
+		moves   string
+		pockets Pockets
+	}{
+		{"start", "", "", Pockets{}},
+		{"pawn captured", "", "e4 d5 exd5", Pockets{White: Pocket{Pawn: 1}}},
+		{"pawns captured by both sides", "", "e4 d5 exd5 Qxd5", Pockets{White: Pocket{Pawn: 1}, Black: Pocket{Pawn: 1}}},
+		{"knight captured", "4k3/8/8/8/8/8/3n4/4K3[] w - - 0 1", "Kxd2", Pockets{White: Pocket{Knight: 1}}},
+		{"promoted knight captured", "4k3/8/8/8/8/8/3n~4/4K3[] w - - 0 1", "Kxd2", Pockets{White: Pocket{Pawn: 1}}},
+		{"piece captured by promoting", "1n2k3/P7/8/8/8/8/8/4K3[] w - - 0 1", "axb8=Q", Pockets{White: Pocket{Knight: 1}}},
+		{"piece promoted then captured", "r3k3/1P6/8/8/8/8/8/4K3[] w - - 0 1", "b8=Q+ Rxb8", Pockets{Black: Pocket{Pawn: 1}}},
+		{"piece dropped", "4k3/8/8/8/8/8/8/4K3[NPp] w - - 0 1", "N@f3", Pockets{White: Pocket{Pawn: 1}, Black: Pocket{Pawn: 1}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := startVariant(t, VariantCrazyhouse, tt.fen)
+			play(t, g, strings.Fields(tt.moves)...)
+
+			if got := *g.Pockets(); got != tt.pockets {
+				t.Errorf("got pockets %+v, want %+v", got, tt.pockets)
+			}
+		})
+	}
+}
+
+func TestCrazyhouseDrops(t *testing.T) {
+	tests := []struct {
+		name string
+		fen  string
+		move string
+		err  error
+	}{
+		{"pawn", "4k3/8/8/8/8/8/8/4K3[P] w - - 0 1", "P@e4", nil},
+		{"pawn without the letter", "4k3/8/8/8/8/8/8/4K3[P] w - - 0 1", "@e4", nil},
+		{"pawn on the last rank", "4k3/8/8/8/8/8/8/4K3[P] w - - 0 1", "P@a8", ErrPawnDrop},
+		{"pawn on the first rank", "4k3/8/8/8/8/8/8/4K3[P] w - - 0 1", "@h1", ErrPawnDrop},
+		{"piece on the last rank", "4k3/8/8/8/8/8/8/4K3[N] w - - 0 1", "N@a8", nil},
+		{"piece not in the pocket", "4k3/8/8/8/8/8/8/4K3[P] w - - 0 1", "N@f3", ErrNotInPocket},
+		{"piece in the opponents pocket", "4k3/8/8/8/8/8/8/4K3[n] w - - 0 1", "N@f3", ErrNotInPocket},
+		{"onto a piece", "4k3/8/8/8/8/8/8/4K3[N] w - - 0 1", "N@e8", ErrIllegalMove},
+		{"blocking a check", "4k3/8/8/8/8/8/8/r3K3[N] w - - 0 1", "N@d1", nil},
+		{"not blocking a check", "4k3/8/8/8/8/8/8/r3K3[N] w - - 0 1", "N@f3", ErrIllegalMove},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := startVariant(t, VariantCrazyhouse, tt.fen)
+
+			err := g.Move(tt.move, "white", time.Now())
+			if errors.Cause(err) != tt.err {
+				t.Errorf("dropping %v: got error %v, want %v", tt.move, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestCrazyhouseFen(t *testing.T) {
+	tests := []struct {
+		name string
+		fen  string
+		err  string
+	}{
+		{"empty pockets", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR[] w KQkq - 0 1", ""},
+		{"pockets", "r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R[QNPqnp] w KQkq - 2 3", ""},
+		{"promoted pieces", "4k3/8/8/8/8/8/3Q~4/1N~2K3[RNPqp] b - - 0 1", ""},
+		{"unknown piece in a pocket", "4k3/8/8/8/8/8/8/4K3[K] w - - 0 1", `'K' can not be in a pocket`},
+		{"unclosed pocket", "4k3/8/8/8/8/8/8/4K3[N w - - 0 1", "pockets must be closed with ]"},
+		{"promoted marker without a piece", "4k3/8/8/8/8/8/8/~4K3[] w - - 0 1", "~ must follow a piece"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := parseFen(tt.fen, crazyhouse{})
+			if tt.err != "" {
+				if errors.Cause(err) != ErrInvalidFen || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("got error %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parsing fen: %v", err)
+			}
+
+			if fen := h.fen(); fen != tt.fen {
+				t.Errorf("got %v, want %v", fen, tt.fen)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package chess
+
+import (
+	"fmt"
+	"math/bits"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/schafer14/MtM/board"
+	"github.com/schafer14/MtM/common"
+	"github.com/schafer14/MtM/move"
+)
+
+const (
+	TerminationInsufficientMaterial Termination = "insufficient material"
+	TerminationThreefoldRepetition  Termination = "threefold repetition"
+	TerminationFivefoldRepetition   Termination = "fivefold repetition"
+	TerminationFiftyMoveRule        Termination = "fifty move rule"
+	TerminationSeventyFiveMoveRule  Termination = "seventy-five move rule"
+)
+
+// ErrNoDrawClaim is returned when a player claims a draw that the rules
+// do not allow.
+var ErrNoDrawClaim = errors.New("no draw can be claimed in this position")
+
+// lightSquares is a bitboard of all the light squares on the board.
+const lightSquares uint64 = 0x55AA55AA55AA55AA
+
+// history tracks the positions reached in a game so that draw rules can be
+// applied.
+type history struct {
+	pos       position
+	positions map[string]int
+	halfmoves int
+	fullmove  int
+
+	// checks are the number of checks given by each color.
+	checks [2]int
+}
+
+func newHistory(p position) *history {
+	h := history{pos: p, positions: map[string]int{}, fullmove: 1}
+	h.positions[positionKey(p)]++
+
+	return &h
+}
+
+// push plays a move on the current position.
+func (h *history) push(m move.Move32) {
+	if m.Piece() == common.Pawn || m.IsCap() {
+		h.halfmoves = 0
+	} else {
+		h.halfmoves++
+	}
+
+	mover := h.pos.board.Turn
+	if mover == common.Black {
+		h.fullmove++
+	}
+
+	h.pos.play(m)
+	h.positions[positionKey(h.pos)]++
+	if h.pos.board.IsInCheck(h.pos.board.Turn) {
+		h.checks[mover]++
+	}
+}
+
+// repetitions counts how many times the current position has occured.
+func (h *history) repetitions() int {
+	return h.positions[positionKey(h.pos)]
+}
+
+// fen returns the fen string of the current position including the move
+// counters.
+func (h *history) fen() string {
+	return fmt.Sprintf("%v %v %v", strings.Join(h.pos.fenFields(), " "), h.halfmoves, h.fullmove)
+}
+
+// automaticDraw checks for draws that end the game without either player
+// claiming them.
+func (h *history) automaticDraw() (bool, Outcome) {
+	if h.pos.variant.insufficientMaterial(h.pos.board) {
+		return true, Outcome{ResultDraw, TerminationInsufficientMaterial}
+	}
+	if h.repetitions() >= 5 {
+		return true, Outcome{ResultDraw, TerminationFivefoldRepetition}
+	}
+	if h.halfmoves >= 150 {
+		return true, Outcome{ResultDraw, TerminationSeventyFiveMoveRule}
+	}
+
+	return false, Outcome{}
+}
+
+// claimableDraw checks for draws that a player may claim.
+func (h *history) claimableDraw() (bool, Outcome) {
+	if h.repetitions() >= 3 {
+		return true, Outcome{ResultDraw, TerminationThreefoldRepetition}
+	}
+	if h.halfmoves >= 100 {
+		return true, Outcome{ResultDraw, TerminationFiftyMoveRule}
+	}
+
+	return false, Outcome{}
+}
+
+// positionKey identifies a position for the repetition rules. The en passant
+// square only counts if an en passant capture is actually possible.
+func positionKey(p position) string {
+	fields := p.fenFields()
+	if fields[3] != "-" && !canEnPassant(p.board, squareNum(fields[3])) {
+		fields[3] = "-"
+	}
+
+	return strings.Join(fields[:4], " ")
+}
+
+// canEnPassant checks if the side to move has a legal en passant capture onto
+// the square.
+func canEnPassant(b board.Board, square uint) bool {
+	moves := b.Moves()
+	for {
+		hasNext, m := moves.Next()
+		if !hasNext {
+			return false
+		}
+		if m.Piece() == common.Pawn && m.IsCap() && m.Dest() == square {
+			return true
+		}
+	}
+}
+
+// squareNum converts a square like e4 into its index on the board.
+func squareNum(square string) uint {
+	return uint(square[1]-'1')*8 + uint(square[0]-'a')
+}
+
+// insufficientMaterial checks if neither side could ever checkmate. This
+// is the case when there are no pawns, rooks or queens and either at most one
+// minor piece or only bishops that all stand on the same color.
+func insufficientMaterial(b board.Board) bool {
+	if b.Pieces[common.Pawn]|b.Pieces[common.Rook]|b.Pieces[common.Queen] != 0 {
+		return false
+	}
+
+	minors := b.Pieces[common.Knight] | b.Pieces[common.Bishop]
+	if bits.OnesCount64(minors) <= 1 {
+		return true
+	}
+
+	bishops := b.Pieces[common.Bishop]
+	return b.Pieces[common.Knight] == 0 && (bishops&lightSquares == 0 || bishops&^lightSquares == 0)
+}
@@ -0,0 +1,83 @@
+package chess
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/schafer14/MtM/board"
+)
+
+func TestDrawRules(t *testing.T) {
+	tests := []struct {
+		name  string
+		fen   string
+		moves string
+		over  Outcome
+		claim Outcome
+	}{
+		{"position repeated twice", "", "Nf3 Nf6 Ng1 Ng8", Outcome{}, Outcome{}},
+		{"threefold repetition", "", "Nf3 Nf6 Ng1 Ng8 Nf3 Nf6 Ng1 Ng8", Outcome{}, Outcome{ResultDraw, TerminationThreefoldRepetition}},
+		{"fivefold repetition", "", "Nf3 Nf6 Ng1 Ng8 Nf3 Nf6 Ng1 Ng8 Nf3 Nf6 Ng1 Ng8 Nf3 Nf6 Ng1 Ng8", Outcome{ResultDraw, TerminationFivefoldRepetition}, Outcome{}},
+		{"49 moves without a capture or pawn move", "4k3/8/8/8/8/8/8/R3K3 w - - 98 60", "Ra2", Outcome{}, Outcome{}},
+		{"fifty move rule", "4k3/8/8/8/8/8/8/R3K3 w - - 99 60", "Ra2", Outcome{}, Outcome{ResultDraw, TerminationFiftyMoveRule}},
+		{"pawn move resets the count", "4k3/8/8/8/8/8/P7/R3K3 w - - 99 60", "a3", Outcome{}, Outcome{}},
+		{"seventy-five move rule", "4k3/8/8/8/8/8/8/R3K3 w - - 149 80", "Ra2", Outcome{ResultDraw, TerminationSeventyFiveMoveRule}, Outcome{}},
+		{"capturing the last pawn", "4k3/8/8/8/8/8/3p4/4K3 w - - 0 1", "Kxd2", Outcome{ResultDraw, TerminationInsufficientMaterial}, Outcome{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := startVariant(t, VariantStandard, tt.fen)
+			play(t, g, strings.Fields(tt.moves)...)
+
+			if g.IsOver() != (tt.over != Outcome{}) || g.IsOver() && g.Outcome() != tt.over {
+				t.Fatalf("got over %v with %v, want %v", g.IsOver(), g.Outcome(), tt.over)
+			}
+			if g.IsOver() {
+				return
+			}
+
+			err := g.ClaimDraw("white", time.Now())
+			if tt.claim == (Outcome{}) {
+				if errors.Cause(err) != ErrNoDrawClaim {
+					t.Errorf("claiming a draw: got error %v, want %v", err, ErrNoDrawClaim)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("claiming a draw: %v", err)
+			}
+			if g.Outcome() != tt.claim {
+				t.Errorf("claimed %v, want %v", g.Outcome(), tt.claim)
+			}
+		})
+	}
+}
+
+func TestInsufficientMaterial(t *testing.T) {
+	tests := []struct {
+		name         string
+		fen          string
+		insufficient bool
+	}{
+		{"kings only", "4k3/8/8/8/8/8/8/4K3 w - - 0 1", true},
+		{"king and knight", "4k3/8/8/8/8/8/8/4KN2 w - - 0 1", true},
+		{"king and bishop", "4k3/8/8/8/8/8/8/4KB2 w - - 0 1", true},
+		{"bishops on the same color", "4kb2/8/8/8/8/8/8/2B1K3 w - - 0 1", true},
+		{"bishops on different colors", "4k1b1/8/8/8/8/8/8/2B1K3 w - - 0 1", false},
+		{"two knights", "4k3/8/8/8/8/8/8/3NKN2 w - - 0 1", false},
+		{"knight and bishop", "4kn2/8/8/8/8/8/8/4KB2 w - - 0 1", false},
+		{"a pawn", "4k3/8/8/8/8/8/P7/4K3 w - - 0 1", false},
+		{"a rook", "4k3/8/8/8/8/8/8/R3K3 w - - 0 1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := insufficientMaterial(board.FromFen(tt.fen)); got != tt.insufficient {
+				t.Errorf("got %v, want %v", got, tt.insufficient)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package chess
+
+import (
+	"time"
+
+	"github.com/schafer14/MtM/common"
+)
+
+// EventType is what happened in a game event.
+type EventType string
+
+const (
+	EventCreated      EventType = "created"
+	EventJoined       EventType = "joined"
+	EventMoved        EventType = "moved"
+	EventDrawOffered  EventType = "drawOffered"
+	EventDrawDeclined EventType = "drawDeclined"
+	EventDrawAccepted EventType = "drawAccepted"
+	EventDrawClaimed  EventType = "drawClaimed"
+	EventResigned     EventType = "resigned"
+	EventAborted      EventType = "aborted"
+	EventFlagged      EventType = "flagged"
+	EventAbandoned    EventType = "abandoned"
+
+	// EventResultRecorded ends an imported game with the result it was
+	// recorded with when its moves do not end it.
+	EventResultRecorded EventType = "resultRecorded"
+
+	EventRematchOffered  EventType = "rematchOffered"
+	EventRematchAccepted EventType = "rematchAccepted"
+
+	EventTakebackProposed EventType = "takebackProposed"
+	EventTakebackDeclined EventType = "takebackDeclined"
+	EventTakenBack        EventType = "takenBack"
+)
+
+// Event is something that happened in a game. The events of a game are
+// only ever added to and replaying them gives the state of the game.
+//
+// Events caused by a player have the id, name and color of the player as
+// the actor. Flagged and abandoned events are found by the server and have
+// no actor, as do the events of finished imported games. Events that end the
+// game have its outcome.
+type Event struct {
+	Seq     int       `json:"seq"`
+	Type    EventType `json:"type"`
+	Time    time.Time `json:"time"`
+	ActorId string    `json:"actorId,omitempty"`
+	Name    string    `json:"name,omitempty"`
+	Color   string    `json:"color,omitempty"`
+
+	// TimeControl, Rated, Visibility, Variant, Fen and Imported are the
+	// settings of created games.
+	TimeControl *TimeControl `json:"timeControl,omitempty"`
+	Rated       bool         `json:"rated,omitempty"`
+	Visibility  string       `json:"visibility,omitempty"`
+	Variant     string       `json:"variant,omitempty"`
+	Fen         string       `json:"fen,omitempty"`
+	Imported    bool         `json:"imported,omitempty"`
+
+	// Move is a move as it is stored in the game and San the same move in
+	// standard algebraic notation.
+	Move string `json:"move,omitempty"`
+	San  string `json:"san,omitempty"`
+
+	// Plies is the number of half moves a takeback took back.
+	Plies int `json:"plies,omitempty"`
+
+	// GameId is the other game of a rematch. Created events have the game
+	// they are a rematch of and accepted rematches have the new game.
+	GameId string `json:"gameId,omitempty"`
+
+	Outcome *Outcome `json:"outcome,omitempty"`
+}
+
+// Events returns the events of the game after the first since events.
+func (g *game) Events(since int) []Event {
+	if since < 0 {
+		since = 0
+	}
+	if since >= len(g.EventLog) {
+		return []Event{}
+	}
+
+	return g.EventLog[since:]
+}
+
+// record adds an event to the game and applies it.
+func (g *game) record(e Event) {
+	e.Seq = len(g.EventLog) + 1
+	if e.Name == "" {
+		switch e.Color {
+		case colorName(common.White):
+			e.Name = g.White
+		case colorName(common.Black):
+			e.Name = g.Black
+		}
+	}
+
+	g.EventLog = append(g.EventLog, e)
+	g.apply(e)
+}
+
+// apply changes the state of the game for an event.
+func (g *game) apply(e Event) {
+	switch e.Type {
+	case EventCreated:
+		g.Date = e.Time
+		g.White = "Unknown"
+		g.Black = "Unknown"
+		g.seat(e)
+		g.Status = StatusInitiating
+		g.Result = ResultNone
+		g.Moves = []string{}
+		g.MoveTimes = []time.Time{}
+		g.TimeControl = e.TimeControl
+		g.Rated = e.Rated
+		g.Visibility = e.Visibility
+		g.Variant = e.Variant
+		g.StartFen = e.Fen
+		g.RematchOf = e.GameId
+		g.Imported = e.Imported
+	case EventJoined:
+		g.seat(e)
+		g.Status = StatusInProgress
+		g.Started = e.Time
+	case EventMoved:
+		g.Moves = append(g.Moves, e.Move)
+		g.MoveTimes = append(g.MoveTimes, e.Time)
+
+		// Moving is an implicit decline of the opponents draw offer.
+		if g.DrawOffer != "" && g.DrawOffer != e.Color {
+			g.DrawOffer = ""
+		}
+
+		// Takebacks are proposed for a position, which no longer exists.
+		g.TakebackProposal = ""
+	case EventDrawOffered:
+		g.DrawOffer = e.Color
+	case EventDrawDeclined, EventDrawAccepted:
+		g.DrawOffer = ""
+	case EventTakebackProposed:
+		g.TakebackProposal = e.Color
+	case EventTakebackDeclined:
+		g.TakebackProposal = ""
+	case EventTakenBack:
+		g.Moves = g.Moves[:len(g.Moves)-e.Plies]
+		g.MoveTimes = g.MoveTimes[:len(g.MoveTimes)-e.Plies]
+		g.TakebackProposal = ""
+	case EventRematchOffered:
+		g.RematchOffer = e.Color
+	case EventRematchAccepted:
+		g.RematchOffer = ""
+		g.RematchId = e.GameId
+	}
+
+	if e.Outcome != nil {
+		g.finish(*e.Outcome)
+	}
+}
+
+// seat puts the actor of an event on the side of its color.
+func (g *game) seat(e Event) {
+	if e.Color == colorName(common.Black) {
+		g.Black = e.Name
+		g.BlackId = e.ActorId
+		return
+	}
+
+	g.White = e.Name
+	g.WhiteId = e.ActorId
+}
+
+// fold derives the state of the game by replaying its events. Games that
+// were imported or stored before games had events keep the state they were
+// stored with.
+func (g *game) fold() {
+	if len(g.EventLog) == 0 || g.EventLog[0].Type != EventCreated {
+		return
+	}
+
+	// Only the state that does not come from events is kept.
+	folded := game{
+		Id:        g.Id,
+		WhiteSeen: g.WhiteSeen,
+		BlackSeen: g.BlackSeen,
+		Version:   g.Version,
+		MoveKeys:  g.MoveKeys,
+		EventLog:  g.EventLog,
+	}
+	for _, e := range g.EventLog {
+		folded.apply(e)
+	}
+
+	*g = folded
+}
@@ -0,0 +1,117 @@
+package chess
+
+import (
+	"time"
+
+	"github.com/schafer14/MtM/common"
+)
+
+const TerminationAbandoned Termination = "abandoned"
+
+// Abandonment configures when a game is treated as abandoned by a player.
+type Abandonment struct {
+	// FirstMove is how long each player has to make their first move.
+	FirstMove time.Duration
+
+	// Disconnect is how long the player to move can be away from the game.
+	Disconnect time.Duration
+}
+
+// Expire ends the game if the player to move has run out of time or has
+// abandoned the game. It reports whether the game was ended.
+//
+// A game where a player never made their first move is finished without a
+// result. A player who disconnects while it is their move loses. Imported
+// games never expire.
+func (g *game) Expire(now time.Time, a Abandonment) bool {
+	if g.Status != StatusInProgress || g.Imported {
+		return false
+	}
+
+	p := g.history().pos
+	b := p.board
+	if g.flag(p, now) {
+		return true
+	}
+
+	var outcome Outcome
+	switch len(g.Moves) {
+	case 0:
+		if a.FirstMove <= 0 || now.Sub(g.Started) <= a.FirstMove {
+			return false
+		}
+		outcome = Outcome{ResultNone, TerminationAbandoned}
+	case 1:
+		if a.FirstMove <= 0 || len(g.MoveTimes) == 0 || now.Sub(g.MoveTimes[0]) <= a.FirstMove {
+			return false
+		}
+		outcome = Outcome{ResultNone, TerminationAbandoned}
+	default:
+		if a.Disconnect <= 0 || g.awayFor(b.Turn, now) <= a.Disconnect {
+			return false
+		}
+		outcome = Outcome{winFor(b.Opp()), TerminationAbandoned}
+	}
+
+	g.record(Event{Type: EventAbandoned, Time: now, Outcome: &outcome})
+
+	return true
+}
+
+// awayFor is how long a player has not been seen following the game. Players
+// that have never followed the game, like bots using the api directly, are
+// never away.
+func (g *game) awayFor(color uint, now time.Time) time.Duration {
+	seen := g.WhiteSeen
+	if color == common.Black {
+		seen = g.BlackSeen
+	}
+	if seen.IsZero() {
+		return 0
+	}
+
+	// A player is never away for longer than it has been their move.
+	if len(g.MoveTimes) > 0 && g.MoveTimes[len(g.MoveTimes)-1].After(seen) {
+		seen = g.MoveTimes[len(g.MoveTimes)-1]
+	}
+
+	return now.Sub(seen)
+}
+
+// schedule works out when the game may expire next. It is called whenever
+// the game is stored.
+func (g *game) schedule() {
+	g.FlagAt, g.MovedAt = nil, nil
+	if g.Status != StatusInProgress || g.Imported {
+		return
+	}
+
+	moved := g.Started
+	if len(g.MoveTimes) > 0 {
+		moved = g.MoveTimes[len(g.MoveTimes)-1]
+	}
+	g.MovedAt = &moved
+
+	if g.clocksRunning() {
+		turn := g.start().pos.board.Turn
+		if len(g.Moves)%2 == 1 {
+			turn = opponent(turn)
+		}
+		flag := moved.Add(g.timeLeft(turn, moved)[turn])
+		g.FlagAt = &flag
+	}
+}
+
+// due checks if the game may have expired at a time. Either the side to
+// move has run out of time or nobody has moved for idle. Games stored before
+// they were scheduled are always due.
+func (g *game) due(now time.Time, idle time.Duration) bool {
+	if g.MovedAt == nil {
+		return !g.Imported && g.Status == StatusInProgress
+	}
+	if g.FlagAt != nil && !g.FlagAt.After(now) {
+		return true
+	}
+
+	return idle > 0 && !g.MovedAt.After(now.Add(-idle))
+}
@@ -0,0 +1,89 @@
+package chess
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDueFilter(t *testing.T) {
+	ctx := context.Background()
+	started := time.Now()
+	store := NewMemoryStore()
+	white, black := Player{Id: "white"}, Player{Id: "black"}
+
+	// start stores a game with the moves played when it started.
+	start := func(tc *TimeControl, moves ...string) *game {
+		g, err := StartGame(ctx, store, white, black, Options{TimeControl: tc}, started)
+		if err != nil {
+			t.Fatalf("starting game: %v", err)
+		}
+		for i, m := range moves {
+			player := white.Id
+			if i%2 == 1 {
+				player = black.Id
+			}
+			if err := g.Move(m, player, started); err != nil {
+				t.Fatalf("playing %v: %v", m, err)
+			}
+		}
+		if err := store.Update(ctx, g); err != nil {
+			t.Fatalf("saving game: %v", err)
+		}
+
+		return g.(*game)
+	}
+
+	timed := start(&TimeControl{Base: 60}, "e4", "e5")
+	untimed := start(nil, "e4")
+	finished := start(nil, "f3", "e5", "g4", "Qh4#")
+
+	// Games stored before they were scheduled have no times.
+	legacy := start(nil)
+	legacy.MovedAt = nil
+	if err := store.put(legacy); err != nil {
+		t.Fatalf("storing legacy game: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		after time.Duration
+		idle  time.Duration
+		due   []*game
+	}{
+		{"before anything is due", 30 * time.Second, 0, []*game{legacy}},
+		{"after white runs out of time", 61 * time.Second, 0, []*game{timed, legacy}},
+		{"before the idle time", 61 * time.Second, 2 * time.Minute, []*game{timed, legacy}},
+		{"after the idle time", 3 * time.Minute, 2 * time.Minute, []*game{timed, untimed, legacy}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			f := GameFilter{Due: started.Add(tt.after), Idle: tt.idle}
+			err := store.List(ctx, f, func(g Game) error {
+				got = append(got, g.ID())
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("listing games: %v", err)
+			}
+
+			var want []string
+			for _, g := range tt.due {
+				want = append(want, g.ID())
+			}
+			sort.Strings(got)
+			sort.Strings(want)
+			if strings.Join(got, " ") != strings.Join(want, " ") {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+
+	if finished.FlagAt != nil || finished.MovedAt != nil {
+		t.Errorf("finished game is scheduled to expire at %v and %v", finished.FlagAt, finished.MovedAt)
+	}
+}
@@ -0,0 +1,241 @@
+package chess
+
+import (
+	"math/bits"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/schafer14/MtM/board"
+	"github.com/schafer14/MtM/common"
+)
+
+// ErrInvalidFen is returned for starting positions that are not written
+// correctly or could not occur in a game.
+var ErrInvalidFen = errors.New("invalid fen")
+
+// castlingSquares are the squares the king and rook need to be on for each
+// castling right.
+var castlingSquares = map[rune]struct {
+	color      uint
+	king, rook uint
+}{
+	'K': {common.White, 4, 7},
+	'Q': {common.White, 4, 0},
+	'k': {common.Black, 60, 63},
+	'q': {common.Black, 60, 56},
+}
+
+// ParseFen reads a position written in Forsyth-Edwards notation and checks
+// that it is a position a game can start from.
+func ParseFen(fen string) (board.Board, error) {
+	h, err := parseFen(fen, standard{})
+	if err != nil {
+		return board.Board{}, err
+	}
+
+	return h.pos.board, nil
+}
+
+// parseFen reads a position and its move counters. The counters are
+// optional and default to the start of a game. Chess960 positions can have
+// their castling rights in X-FEN or Shredder-FEN and Crazyhouse positions
+// can have their pockets after the piece placement, as in [Qnp].
+func parseFen(fen string, v Variant) (*history, error) {
+	fields := strings.Fields(fen)
+	if len(fields) != 4 && len(fields) != 6 {
+		return nil, errors.Wrap(ErrInvalidFen, "expected 4 or 6 fields")
+	}
+
+	var pockets [2][5]int
+	var promoted uint64
+	if v.pockets() {
+		var err error
+		fields[0], pockets, promoted, err = parsePocketPlacement(fields[0])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := checkPlacement(fields[0]); err != nil {
+		return nil, err
+	}
+	if fields[1] != "w" && fields[1] != "b" {
+		return nil, errors.Wrap(ErrInvalidFen, "side to move must be w or b")
+	}
+
+	halfmoves, fullmove := 0, 1
+	if len(fields) == 6 {
+		var err error
+		halfmoves, err = strconv.Atoi(fields[4])
+		if err != nil || halfmoves < 0 {
+			return nil, errors.Wrap(ErrInvalidFen, "halfmove clock can not be negative")
+		}
+		fullmove, err = strconv.Atoi(fields[5])
+		if err != nil || fullmove < 1 {
+			return nil, errors.Wrap(ErrInvalidFen, "fullmove number must be at least 1")
+		}
+	}
+
+	p := newPosition(board.Board{}, v)
+	if v.chess960() {
+		// The board is kept without castling rights so that MtM never
+		// generates standard castling moves.
+		p.board = board.FromFen(strings.Join([]string{fields[0], fields[1], "-", fields[3]}, " "))
+	} else {
+		p.board = board.FromFen(strings.Join(fields[:4], " "))
+	}
+	p.pockets = pockets
+	p.promoted = promoted & (p.board.Colors[common.White] | p.board.Colors[common.Black])
+	b := p.board
+
+	if err := checkPieces(b); err != nil {
+		return nil, err
+	}
+	// Dropped pieces can take a side past the pieces it starts with.
+	if !v.pockets() {
+		if err := checkArmy(b); err != nil {
+			return nil, err
+		}
+	}
+	if v.chess960() {
+		rooks, err := parseChess960Castling(b, fields[2])
+		if err != nil {
+			return nil, err
+		}
+		p.rooks = rooks
+	} else if err := checkCastling(b, fields[2]); err != nil {
+		return nil, err
+	}
+	if err := checkEnPassant(b, fields[3]); err != nil {
+		return nil, err
+	}
+	if b.IsInCheck(b.Opp()) {
+		return nil, errors.Wrap(ErrInvalidFen, "the side not to move is in check")
+	}
+	if len(p.moves()) == 0 {
+		return nil, errors.Wrap(ErrInvalidFen, "the side to move has no legal moves")
+	}
+	if v.insufficientMaterial(b) {
+		return nil, errors.Wrap(ErrInvalidFen, "neither side has enough material to checkmate")
+	}
+
+	h := newHistory(p)
+	h.halfmoves = halfmoves
+	h.fullmove = fullmove
+
+	if over, _ := v.outcome(h); over {
+		return nil, errors.Wrapf(ErrInvalidFen, "the game is already over in %s", v.Name())
+	}
+
+	return h, nil
+}
+
+// checkPlacement checks the piece placement field has eight ranks of eight
+// squares.
+func checkPlacement(placement string) error {
+	ranks := strings.Split(placement, "/")
+	if len(ranks) != 8 {
+		return errors.Wrap(ErrInvalidFen, "expected 8 ranks")
+	}
+
+	for _, rank := range ranks {
+		squares := 0
+		for _, c := range rank {
+			switch {
+			case c >= '1' && c <= '8':
+				squares += int(c - '0')
+			case strings.ContainsRune("pnbrqkPNBRQK", c):
+				squares++
+			default:
+				return errors.Wrapf(ErrInvalidFen, "unknown piece %q", c)
+			}
+		}
+		if squares != 8 {
+			return errors.Wrap(ErrInvalidFen, "every rank must have 8 squares")
+		}
+	}
+
+	return nil
+}
+
+// checkPieces checks both sides have exactly one king and there are no pawns
+// on the first or last rank.
+func checkPieces(b board.Board) error {
+	for _, color := range []uint{common.White, common.Black} {
+		if bits.OnesCount64(b.Colors[color]&b.Pieces[common.King]) != 1 {
+			return errors.Wrapf(ErrInvalidFen, "%s must have exactly one king", colorName(color))
+		}
+	}
+
+	if b.Pieces[common.Pawn]&(common.Row1|common.Row8) != 0 {
+		return errors.Wrap(ErrInvalidFen, "pawns can not be on the first or last rank")
+	}
+
+	return nil
+}
+
+// checkArmy checks neither side has more than the 8 pawns and 16 pieces it
+// starts with.
+func checkArmy(b board.Board) error {
+	for _, color := range []uint{common.White, common.Black} {
+		if bits.OnesCount64(b.Colors[color]&b.Pieces[common.Pawn]) > 8 {
+			return errors.Wrapf(ErrInvalidFen, "%s has more than 8 pawns", colorName(color))
+		}
+		if bits.OnesCount64(b.Colors[color]) > 16 {
+			return errors.Wrapf(ErrInvalidFen, "%s has more than 16 pieces", colorName(color))
+		}
+	}
+
+	return nil
+}
+
+// checkCastling checks the king and rook of every castling right are still
+// on their starting squares.
+func checkCastling(b board.Board, castling string) error {
+	if castling == "-" {
+		return nil
+	}
+
+	for i, right := range castling {
+		squares, ok := castlingSquares[right]
+		if !ok || strings.IndexRune(castling, right) != i {
+			return errors.Wrapf(ErrInvalidFen, "invalid castling rights %q", castling)
+		}
+
+		own := b.Colors[squares.color]
+		if own&b.Pieces[common.King]&(1<<squares.king) == 0 || own&b.Pieces[common.Rook]&(1<<squares.rook) == 0 {
+			return errors.Wrapf(ErrInvalidFen, "castling right %c needs the king and rook on their starting squares", right)
+		}
+	}
+
+	return nil
+}
+
+// checkEnPassant checks the en passant square is behind a pawn that could
+// have just moved two squares.
+func checkEnPassant(b board.Board, square string) error {
+	if square == "-" {
+		return nil
+	}
+
+	if len(square) != 2 || square[0] < 'a' || square[0] > 'h' {
+		return errors.Wrapf(ErrInvalidFen, "invalid en passant square %q", square)
+	}
+
+	// The pawn that moved belongs to the side that is not to move.
+	rank, pawn, from := byte('6'), squareNum(square)-8, squareNum(square)+8
+	if b.Turn == common.Black {
+		rank, pawn, from = '3', squareNum(square)+8, squareNum(square)-8
+	}
+	if square[1] != rank {
+		return errors.Wrapf(ErrInvalidFen, "invalid en passant square %q", square)
+	}
+
+	all := b.Colors[common.White] | b.Colors[common.Black]
+	if b.Colors[b.Opp()]&b.Pieces[common.Pawn]&(1<<pawn) == 0 || all&(1<<squareNum(square)|1<<from) != 0 {
+		return errors.Wrapf(ErrInvalidFen, "no pawn could have just moved past %s", square)
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package chess
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestParseFen(t *testing.T) {
+	tests := []struct {
+		name    string
+		variant string
+		fen     string
+		err     string
+	}{
+		{"starting position", VariantStandard, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", ""},
+		{"without move counters", VariantStandard, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq -", ""},
+		{"en passant", VariantStandard, "rnbqkbnr/pppp1ppp/8/4p3/4P3/8/PPPP1PPP/RNBQKBNR w KQkq e6 0 2", ""},
+		{"missing fields", VariantStandard, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq", "expected 4 or 6 fields"},
+		{"seven ranks", VariantStandard, "rnbqkbnr/pppppppp/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "expected 8 ranks"},
+		{"nine squares", VariantStandard, "rnbqkbnr/pppppppp/p8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "every rank must have 8 squares"},
+		{"unknown piece", VariantStandard, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBXKBNR w KQkq - 0 1", "unknown piece 'X'"},
+		{"unknown side to move", VariantStandard, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR x KQkq - 0 1", "side to move must be w or b"},
+		{"negative halfmove clock", VariantStandard, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - -1 1", "halfmove clock can not be negative"},
+		{"fullmove number zero", VariantStandard, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 0", "fullmove number must be at least 1"},
+		{"two kings", VariantStandard, "4k3/8/8/8/8/8/8/R2KK3 w - - 0 1", "white must have exactly one king"},
+		{"no king", VariantStandard, "8/8/8/8/8/8/8/R3K3 w - - 0 1", "black must have exactly one king"},
+		{"pawn on the first rank", VariantStandard, "4k3/8/8/8/8/8/8/P3K3 w - - 0 1", "pawns can not be on the first or last rank"},
+		{"nine pawns", VariantStandard, "4k3/8/8/P7/8/8/PPPPPPPP/4K3 w - - 0 1", "white has more than 8 pawns"},
+		{"seventeen pieces", VariantStandard, "4k3/8/8/8/NNNNNNNN/8/PPPPPPPP/4K3 w - - 0 1", "white has more than 16 pieces"},
+		{"unknown castling right", VariantStandard, "r3k2r/8/8/8/8/8/8/R3K2R w KX - 0 1", "invalid castling rights"},
+		{"repeated castling right", VariantStandard, "r3k2r/8/8/8/8/8/8/R3K2R w KK - 0 1", "invalid castling rights"},
+		{"castling without the rook", VariantStandard, "r3k2r/8/8/8/8/8/8/R3K3 w KQ - 0 1", "castling right K needs the king and rook"},
+		{"en passant on the wrong rank", VariantStandard, "rnbqkbnr/pppp1ppp/8/4p3/4P3/8/PPPP1PPP/RNBQKBNR w KQkq e3 0 2", "invalid en passant square"},
+		{"en passant for the side to move", VariantStandard, "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR w KQkq e3 0 1", "invalid en passant square"},
+		{"en passant without a pawn", VariantStandard, "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR w KQkq d6 0 1", "no pawn could have just moved past d6"},
+		{"side not to move in check", VariantStandard, "4k3/8/8/8/8/8/8/4R1K1 w - - 0 1", "the side not to move is in check"},
+		{"stalemate", VariantStandard, "7k/5Q2/6K1/8/8/8/8/8 b - - 0 1", "the side to move has no legal moves"},
+		{"checkmate", VariantStandard, "R5k1/5ppp/8/8/8/8/8/6K1 b - - 0 1", "the side to move has no legal moves"},
+		{"insufficient material", VariantStandard, "4k3/8/8/8/8/8/8/4KB2 w - - 0 1", "neither side has enough material to checkmate"},
+		{"game already over", VariantKingOfTheHill, "4k3/8/8/8/3K4/8/8/R7 b - - 0 1", "the game is already over in kingOfTheHill"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseFen(tt.fen, variants[tt.variant])
+			if tt.err == "" {
+				if err != nil {
+					t.Errorf("got error %v, want none", err)
+				}
+				return
+			}
+			if errors.Cause(err) != ErrInvalidFen || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got error %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
@@ -3,22 +3,78 @@ package chess
 import (
 	"context"
 	"fmt"
+	"io"
+	"math/rand"
 	"time"
 
 	"github.com/pkg/errors"
 	"github.com/schafer14/MtM/board"
-	"go.mongodb.org/mongo-driver/bson"
+	"github.com/schafer14/MtM/common"
+	"github.com/schafer14/MtM/move"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type Game interface {
-	Save(context.Context, *mongo.Collection) error
-	Join(Player)
-	Move(string, string) error
+	ID() string
+	Ply() int
+	Join(Player, time.Time) error
+	Move(string, string, time.Time) error
+	Played(string, string, string) (bool, error)
+	RememberMove(string, string, string)
 	Fen() string
+	SAN() []string
+	Clock(time.Time) *Clock
+	Checks() *Checks
+	Pockets() *Pockets
+	Expire(time.Time, Abandonment) bool
+	ClaimDraw(string, time.Time) error
+	Resign(string, time.Time) error
+	OfferDraw(string, time.Time) error
+	AcceptDraw(string, time.Time) error
+	DeclineDraw(string, time.Time) error
+	Abort(string, time.Time) error
+	ProposeTakeback(string, time.Time) error
+	AcceptTakeback(string, time.Time) error
+	DeclineTakeback(string, time.Time) error
+	Events(int) []Event
+	Visible(string) bool
+	Plays(string) bool
+	IsRated() bool
+	Rematch(string, primitive.ObjectID, time.Time) (Game, error)
+	IsOver() bool
+	Outcome() Outcome
+	WritePGN(io.Writer) error
 }
 
+var (
+	// ErrGameOver is returned when trying to play in a game that has finished.
+	ErrGameOver = errors.New("game is over")
+
+	// ErrNotParticipant is returned when someone who is not playing in a game
+	// tries to act on it.
+	ErrNotParticipant = errors.New("not a participant in this game")
+
+	// ErrNotStarted is returned for actions that need an opponent before
+	// the second player has joined.
+	ErrNotStarted = errors.New("game has not started")
+
+	// ErrGameFull is returned when joining a game that already has two
+	// players.
+	ErrGameFull = errors.New("game already has two players")
+
+	// ErrUnknownColor is returned when creating a game with a color other
+	// than white, black or random.
+	ErrUnknownColor = errors.New("color must be white, black or random")
+
+	// ErrConflict is returned when saving a game that was changed by another
+	// request since it was loaded.
+	ErrConflict = errors.New("game was changed by another request")
+
+	// ErrMoveIdReused is returned when a player sends the id of a move they
+	// played again with a different move.
+	ErrMoveIdReused = errors.New("the move id was already used for a different move")
+)
+
 type game struct {
 	Id            primitive.ObjectID `json:"id" bson:"_id"`
 	Date          time.Time          `json:"date"`
@@ -26,11 +82,61 @@ type game struct {
 	WhiteId       string             `json:"whiteId"`
 	Black         string             `json:"black"`
 	BlackId       string             `json:"blackId"`
+	Variant       string             `json:"variant,omitempty"`
+	StartFen      string             `json:"startFen,omitempty"`
 	FenString     string             `json:"fen" bson:"-"`
+	CheckCount    *Checks            `json:"checks,omitempty" bson:"-"`
+	PocketState   *Pockets           `json:"pockets,omitempty" bson:"-"`
 	ControlsWhite bool               `json:"controlsWhite" bson:"-"`
 	ControlsBlack bool               `json:"controlsBlack" bson:"-"`
 	Moves         []string           `json:"moves"`
+	MoveTimes     []time.Time        `json:"moveTimes"`
+	TimeControl   *TimeControl       `json:"timeControl,omitempty"`
+	Rated         bool               `json:"rated"`
+	Visibility    string             `json:"visibility"`
+	SANMoves      []string           `json:"san" bson:"-"`
+	ClockState    *Clock             `json:"clock,omitempty" bson:"-"`
+	Started       time.Time          `json:"started"`
+	WhiteSeen     time.Time          `json:"-"`
+	BlackSeen     time.Time          `json:"-"`
 	Status        status             `json:"status"`
+	Result        Result             `json:"result"`
+	Termination   Termination        `json:"termination,omitempty"`
+	DrawOffer     string             `json:"drawOffer,omitempty"`
+
+	// FlagAt is when the side to move runs out of time and MovedAt is when
+	// the last move was made, or when the game started before any moves.
+	// Both are only kept for games that can expire so that the sweeper can
+	// find the games that may have expired without replaying all of them.
+	FlagAt  *time.Time `json:"-" bson:"flagat,omitempty"`
+	MovedAt *time.Time `json:"-" bson:"movedat,omitempty"`
+
+	// Imported games were played somewhere else. They have no clocks and
+	// nobody can abandon them.
+	Imported bool `json:"imported,omitempty"`
+
+	// TakebackProposal is the color of the player asking to take back their
+	// last move.
+	TakebackProposal string `json:"takebackProposal,omitempty"`
+
+	// RematchOffer is the color of the player offering a rematch. RematchId
+	// is the rematch of the game once it started and RematchOf the game
+	// this game is a rematch of.
+	RematchOffer string `json:"rematchOffer,omitempty"`
+	RematchId    string `json:"rematchId,omitempty"`
+	RematchOf    string `json:"rematchOf,omitempty"`
+
+	// Version counts the times the game has been saved. Saving a game only
+	// succeeds if nobody else has saved it since it was loaded.
+	Version int `json:"version"`
+
+	// MoveKeys are the idempotency keys of the most recent moves so that
+	// retried moves are not played twice. Keys stored before they were kept
+	// per player are left under movekeys and no longer read.
+	MoveKeys []moveKey `json:"-" bson:"playerkeys"`
+
+	// EventLog is everything that happened in the game.
+	EventLog []Event `json:"-" bson:"events"`
 }
 
 type status int
@@ -41,72 +147,205 @@ const (
 	StatusDone
 )
 
+// DefaultRating is the rating of players who do not have a rating yet.
+const DefaultRating = 1500
+
 type Player struct {
-	Id   string `json:"id"`
-	Name string `json:"name"`
+	Id     string `json:"id"`
+	Name   string `json:"name"`
+	Rating int    `json:"rating"`
 }
 
-func NewGame(id primitive.ObjectID, date time.Time, p Player) Game {
+// Options are the settings a game is created with.
+type Options struct {
+	// TimeControl is the time each player has. Games without a time control
+	// are untimed.
+	TimeControl *TimeControl
+
+	// Fen is the position the game starts from. Games without a position
+	// start from the standard starting position.
+	Fen string
+
+	// Variant is the variant of chess played. Games without a variant are
+	// standard chess.
+	Variant string
+
+	// Chess960Position is the number of the start position of a Chess960
+	// game. A random position is picked when there is no number or fen.
+	Chess960Position *int
+
+	// Rated games count towards the ratings of the players.
+	Rated bool
+
+	// Color is the side the creator of the game plays: white, black or
+	// random. Creators play white when there is no color.
+	Color string
+
+	// Visibility is who can see the game: public, unlisted or private.
+	// Games are public when there is no visibility.
+	Visibility string
+
+	// RematchOf is the game this game is a rematch of.
+	RematchOf string
+}
+
+func NewGame(id primitive.ObjectID, date time.Time, p Player, opts Options) (Game, error) {
+	name := opts.Variant
+	if name == "" {
+		name = VariantStandard
+	}
+	variant, ok := variants[name]
+	if !ok {
+		return nil, ErrUnknownVariant
+	}
+
+	color := common.White
+	switch opts.Color {
+	case "", colorName(common.White):
+	case colorName(common.Black):
+		color = common.Black
+	case "random":
+		if rand.Intn(2) == 0 {
+			color = common.Black
+		}
+	default:
+		return nil, ErrUnknownColor
+	}
+
+	visibility := opts.Visibility
+	switch visibility {
+	case "":
+		visibility = VisibilityPublic
+	case VisibilityPublic, VisibilityUnlisted, VisibilityPrivate:
+	default:
+		return nil, ErrUnknownVisibility
+	}
+
+	fen, err := variant.start(opts)
+	if err != nil {
+		return nil, err
+	}
+	if fen != "" {
+		if _, err := parseFen(fen, variant); err != nil {
+			return nil, err
+		}
+	}
+
 	var g = game{}
 	g.Id = id
-	g.White = p.Name
-	g.Black = "Unknown"
-	g.WhiteId = p.Id
-	g.Date = date
-	g.Status = StatusInitiating
-	g.Moves = []string{}
-	g.FenString = board.New().String()
-	g.ControlsWhite = true
-
-	return &g
-}
-
-func (g *game) Join(p Player) {
-	g.Black = p.Name
-	g.BlackId = p.Id
-	g.Status = StatusInProgress
+	g.record(Event{
+		Type:        EventCreated,
+		Time:        date,
+		ActorId:     p.Id,
+		Name:        p.Name,
+		Color:       colorName(color),
+		TimeControl: opts.TimeControl,
+		Rated:       opts.Rated,
+		Visibility:  visibility,
+		Variant:     name,
+		GameId:      opts.RematchOf,
+		Fen:         fen,
+	})
+	g.SANMoves = []string{}
+	g.ClockState = g.Clock(date)
 	g.FenString = g.Fen()
-	g.ControlsBlack = true
+	g.CheckCount = g.Checks()
+	g.PocketState = g.Pockets()
+	g.ControlsWhite = color == common.White
+	g.ControlsBlack = color == common.Black
+
+	return &g, nil
+}
+
+// Join seats a player on the side of the game that is still empty.
+func (g *game) Join(p Player, now time.Time) error {
+	if g.IsOver() {
+		return ErrGameOver
+	}
+
+	var color uint
+	switch {
+	case g.WhiteId == "":
+		color = common.White
+	case g.BlackId == "":
+		color = common.Black
+	default:
+		return ErrGameFull
+	}
+
+	g.record(Event{Type: EventJoined, Time: now, ActorId: p.Id, Name: p.Name, Color: colorName(color)})
+	g.FenString = g.Fen()
+	g.CheckCount = g.Checks()
+	g.PocketState = g.Pockets()
 	if g.WhiteId == p.Id {
 		g.ControlsWhite = true
 	}
-	return
-}
+	if g.BlackId == p.Id {
+		g.ControlsBlack = true
+	}
 
-func (g *game) Save(ctx context.Context, coll *mongo.Collection) error {
+	return nil
+}
 
-	result, err := coll.ReplaceOne(ctx, bson.D{primitive.E{Key: "_id", Value: g.Id}}, g)
+// StartGame creates and stores a game between two players who were paired
+// up before the game, as seen by a spectator.
+func StartGame(ctx context.Context, store GameStore, white, black Player, opts Options, now time.Time) (Game, error) {
+	gm, err := NewGame(primitive.NewObjectID(), now, white, opts)
 	if err != nil {
-		return errors.Wrap(err, "saving game")
+		return nil, err
 	}
-	if result.MatchedCount == 0 {
-		_, err := coll.InsertOne(ctx, g)
-		if err != nil {
-			return errors.Wrap(err, "saving game")
-		}
+	if err := gm.Join(black, now); err != nil {
+		return nil, err
 	}
 
-	return nil
+	if err := store.Create(ctx, gm); err != nil {
+		return nil, err
+	}
+
+	g := gm.(*game)
+	g.load(Player{}, now)
+
+	return g, nil
 }
 
-func FindById(ctx context.Context, coll *mongo.Collection, id string, p Player) (Game, error) {
-	var g game
-	oid, err := primitive.ObjectIDFromHex(id)
+// FindById loads a game from the store as seen by a player.
+func FindById(ctx context.Context, store GameStore, id string, p Player) (Game, error) {
+	gm, err := store.Get(ctx, id)
 	if err != nil {
-		return nil, errors.Wrap(err, "getting object id")
+		return nil, err
 	}
-	filter := bson.D{primitive.E{Key: "_id", Value: oid}}
+	g := gm.(*game)
+	g.load(p, time.Now())
 
-	err = coll.FindOne(context.Background(), filter).Decode(&g)
+	return g, nil
+}
 
+// FindGames lists the games a filter picks from the store as seen by a
+// player.
+func FindGames(ctx context.Context, store GameStore, f GameFilter, p Player) ([]Game, error) {
+	games := []Game{}
+	err := store.List(ctx, f, func(gm Game) error {
+		g := gm.(*game)
+		g.load(p, time.Now())
+		games = append(games, g)
+		return nil
+	})
 	if err != nil {
-		return nil, errors.Wrap(err, "retrieving game")
+		return nil, err
 	}
 
-	b := board.New()
+	return games, nil
+}
 
-	b.ApplyMoves(g.Moves)
-	g.FenString = b.String()
+// load derives the state of a stored game and fills in the fields that are
+// only sent to a player.
+func (g *game) load(p Player, now time.Time) {
+	g.fold()
+	g.FenString = g.Fen()
+	g.CheckCount = g.Checks()
+	g.PocketState = g.Pockets()
+	g.SANMoves = g.SAN()
+	g.ClockState = g.Clock(now)
 
 	if g.WhiteId == p.Id {
 		g.ControlsWhite = true
@@ -114,18 +353,34 @@ func FindById(ctx context.Context, coll *mongo.Collection, id string, p Player)
 	if g.BlackId == p.Id {
 		g.ControlsBlack = true
 	}
+	if g.White == "" {
+		g.White = "Unknown"
+	}
 	if g.Black == "" {
 		g.Black = "Unknown"
 	}
-
-	return &g, nil
-
+	if g.Result == "" {
+		g.Result = ResultNone
+	}
+	if g.Variant == "" {
+		g.Variant = VariantStandard
+	}
+	if g.Visibility == "" {
+		g.Visibility = VisibilityPublic
+	}
 }
 
-func (g *game) Move(move string, playerId string) error {
-	b := board.New()
+// Move plays a move for a player at the given time. The move can be written
+// in UCI, long algebraic or standard algebraic notation and is stored in UCI
+// notation. If the player has run out of time the game ends and ErrFlagFall
+// is returned.
+func (g *game) Move(move string, playerId string, now time.Time) error {
+	if g.IsOver() {
+		return ErrGameOver
+	}
 
-	b.ApplyMoves(g.Moves)
+	h := g.history()
+	b := h.pos.board
 
 	if b.Turn == 0 && playerId != g.WhiteId {
 		return fmt.Errorf("whites move: expected player %v to move but go %v", g.WhiteId, playerId)
@@ -134,23 +389,191 @@ func (g *game) Move(move string, playerId string) error {
 		return fmt.Errorf("blacks move: expected player %v to move but go %v", g.BlackId, playerId)
 	}
 
-	m, err := b.MoveFromSrcDestNotation(move)
+	if g.flag(h.pos, now) {
+		return ErrFlagFall
+	}
+
+	m, err := parseMove(h.pos, move)
 	if err != nil {
-		return fmt.Errorf("Invalid move format")
+		return err
 	}
 
-	if !b.IsLegal(m) {
-		return fmt.Errorf("Illegal move")
+	e := Event{Type: EventMoved, Time: now, ActorId: playerId, Color: colorName(b.Turn), Move: m.String(), San: writeSAN(h.pos, m)}
+	if isDrop(m) {
+		e.Move = dropString(m)
 	}
 
-	g.Moves = append(g.Moves, move)
+	h.push(m)
+	if over, outcome := h.outcome(); over {
+		e.Outcome = &outcome
+	}
+	g.record(e)
 
 	return nil
 }
 
+// recentMoveKeys is how many idempotency keys of moves are kept per game.
+const recentMoveKeys = 20
+
+// moveKey is the idempotency key a player sent with a move.
+type moveKey struct {
+	PlayerId string
+	Key      string
+	Move     string
+}
+
+// Played reports whether the player has played a move with the idempotency
+// key. Keys are kept per player so nobody can reuse the key of another
+// player, and sending a key again with a different move fails with
+// ErrMoveIdReused.
+func (g *game) Played(playerId string, key string, move string) (bool, error) {
+	for _, k := range g.MoveKeys {
+		if k.PlayerId != playerId || k.Key != key {
+			continue
+		}
+		if k.Move != move {
+			return false, ErrMoveIdReused
+		}
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// RememberMove records the idempotency key of the move a player just played.
+// Only the most recent keys are kept.
+func (g *game) RememberMove(playerId string, key string, move string) {
+	g.MoveKeys = append(g.MoveKeys, moveKey{PlayerId: playerId, Key: key, Move: move})
+	if len(g.MoveKeys) > recentMoveKeys {
+		g.MoveKeys = g.MoveKeys[len(g.MoveKeys)-recentMoveKeys:]
+	}
+}
+
+// ClaimDraw ends the game in a draw by threefold repetition or the fifty
+// move rule if either applies to the current position.
+func (g *game) ClaimDraw(playerId string, now time.Time) error {
+	if g.IsOver() {
+		return ErrGameOver
+	}
+	color, err := g.colorOf(playerId)
+	if err != nil {
+		return err
+	}
+
+	ok, outcome := g.history().claimableDraw()
+	if !ok {
+		return ErrNoDrawClaim
+	}
+
+	g.record(Event{Type: EventDrawClaimed, Time: now, ActorId: playerId, Color: colorName(color), Outcome: &outcome})
+
+	return nil
+}
+
+// SAN returns the moves of the game in standard algebraic notation.
+func (g *game) SAN() []string {
+	moves := make([]string, 0, len(g.Moves))
+	g.replay(func(h *history, m move.Move32) {
+		moves = append(moves, writeSAN(h.pos, m))
+	})
+
+	return moves
+}
+
 func (g *game) Fen() string {
-	b := board.New()
-	b.ApplyMoves(g.Moves)
+	return g.history().fen()
+}
+
+// Ply returns the number of half moves that have been played.
+func (g *game) Ply() int {
+	return len(g.Moves)
+}
+
+// IsRated checks if the game counts towards the ratings of the players.
+func (g *game) IsRated() bool {
+	return g.Rated
+}
+
+// ID returns the hex id of the game.
+func (g *game) ID() string {
+	return g.Id.Hex()
+}
+
+// IsOver reports whether the game has finished.
+func (g *game) IsOver() bool {
+	return g.Status == StatusDone
+}
+
+// Outcome returns the result of the game and why it ended.
+func (g *game) Outcome() Outcome {
+	return Outcome{g.Result, g.Termination}
+}
+
+// finish ends the game with the given outcome.
+func (g *game) finish(o Outcome) {
+	g.Status = StatusDone
+	g.Result = o.Result
+	g.Termination = o.Termination
+}
+
+// colorOf finds the side a player is playing. If a player is playing
+// themselves the side to move is used.
+func (g *game) colorOf(playerId string) (uint, error) {
+	if !g.isParticipant(playerId) {
+		return 0, ErrNotParticipant
+	}
+	if g.WhiteId == g.BlackId {
+		return g.history().pos.board.Turn, nil
+	}
+	if playerId == g.WhiteId {
+		return common.White, nil
+	}
+
+	return common.Black, nil
+}
+
+// isParticipant checks if a player is playing either side of the game.
+func (g *game) isParticipant(playerId string) bool {
+	return playerId != "" && (playerId == g.WhiteId || playerId == g.BlackId)
+}
+
+// history replays the moves of the game to get the current position.
+func (g *game) history() *history {
+	return g.replay(nil)
+}
+
+// replay plays through the moves of the game. If visit is given it is
+// called with the position before each move and the move played in it.
+func (g *game) replay(visit func(*history, move.Move32)) *history {
+	h := g.start()
+	for _, moveStr := range g.Moves {
+		m, _ := parseMove(h.pos, moveStr)
+		if visit != nil {
+			visit(h, m)
+		}
+		h.push(m)
+	}
+
+	return h
+}
+
+// start is the position the game started from along with its move
+// counters. Starting positions are checked when the game is created.
+func (g *game) start() *history {
+	if g.StartFen != "" {
+		if h, err := parseFen(g.StartFen, g.variant()); err == nil {
+			return h
+		}
+	}
+
+	return newHistory(newPosition(board.New(), g.variant()))
+}
+
+// variant is the variant the game is played in.
+func (g *game) variant() Variant {
+	if v, ok := variants[g.Variant]; ok {
+		return v
+	}
 
-	return b.String()
+	return standard{}
 }
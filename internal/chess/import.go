@@ -0,0 +1,99 @@
+package chess
+
+import (
+	"time"
+
+	"github.com/schafer14/MtM/board"
+	"github.com/schafer14/MtM/common"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Imported is a game recorded somewhere else, like a PGN file.
+type Imported struct {
+	White string
+	Black string
+	Date  time.Time
+
+	// Fen is the position the game started from if it was not the standard
+	// starting position. It must be a valid starting position.
+	Fen string
+
+	// Moves are the moves of the game in src-dest notation. They must all be
+	// legal.
+	Moves []string
+
+	// Result is the recorded result of the game. Games that are not finished
+	// have the result *.
+	Result Result
+
+	// Termination is the value of the PGN Termination tag if there was one.
+	Termination string
+
+	// Owner is the player importing the game. Unfinished games can be
+	// continued by the owner playing both sides.
+	Owner Player
+}
+
+// importTerminations maps values of the PGN Termination tag onto the reason a
+// game finished. Games without a known reason have no termination.
+var importTerminations = map[string]Termination{
+	"time forfeit": TerminationTimeout,
+	"abandoned":    TerminationAbandoned,
+}
+
+// Import creates a game from a game recorded somewhere else. If the moves
+// finish the game the position decides the outcome, otherwise the recorded
+// result is used. The game is built from events like any other game, all
+// happening at the date of the game.
+func Import(id primitive.ObjectID, imp Imported) Game {
+	h := newHistory(newPosition(board.New(), standard{}))
+	if imp.Fen != "" {
+		if start, err := parseFen(imp.Fen, standard{}); err == nil {
+			h = start
+		}
+	}
+
+	// The moves are worked out first as only unfinished games have players.
+	var moves []Event
+	for _, moveStr := range imp.Moves {
+		m, err := parseMove(h.pos, moveStr)
+		if err != nil {
+			break
+		}
+		e := Event{Type: EventMoved, Time: imp.Date, Color: colorName(h.pos.board.Turn), Move: m.String(), San: writeSAN(h.pos, m)}
+		h.push(m)
+		moves = append(moves, e)
+	}
+
+	over, outcome := h.outcome()
+	recorded := imp.Result == ResultWhiteWins || imp.Result == ResultBlackWins || imp.Result == ResultDraw
+	if over && len(moves) > 0 {
+		moves[len(moves)-1].Outcome = &outcome
+	}
+
+	var ownerId string
+	if !over && !recorded {
+		ownerId = imp.Owner.Id
+	}
+
+	var g = game{}
+	g.Id = id
+	g.record(Event{Type: EventCreated, Time: imp.Date, ActorId: ownerId, Name: imp.White, Color: colorName(common.White), Variant: VariantStandard, Visibility: VisibilityPublic, Fen: imp.Fen, Imported: true})
+	g.record(Event{Type: EventJoined, Time: imp.Date, ActorId: ownerId, Name: imp.Black, Color: colorName(common.Black)})
+	for _, e := range moves {
+		e.ActorId = ownerId
+		g.record(e)
+	}
+
+	switch {
+	case over && len(moves) == 0:
+		g.record(Event{Type: EventResultRecorded, Time: imp.Date, Outcome: &outcome})
+	case !over && recorded:
+		g.record(Event{Type: EventResultRecorded, Time: imp.Date, Outcome: &Outcome{imp.Result, importTerminations[imp.Termination]}})
+	}
+
+	g.FenString = h.fen()
+	g.SANMoves = g.SAN()
+
+	return &g
+}
@@ -0,0 +1,41 @@
+package chess
+
+import (
+	"github.com/schafer14/MtM/board"
+	"github.com/schafer14/MtM/common"
+)
+
+const TerminationKingOfTheHill Termination = "king reached the center"
+
+// hill is a bitboard of the four center squares d4, e4, d5 and e5.
+const hill uint64 = 0x0000001818000000
+
+// kingOfTheHill is standard chess where a player also wins by getting their
+// king to one of the four center squares.
+type kingOfTheHill struct {
+	standard
+}
+
+func (kingOfTheHill) Name() string {
+	return VariantKingOfTheHill
+}
+
+func (kingOfTheHill) outcome(h *history) (bool, Outcome) {
+	b := h.pos.board
+	mover := b.Opp()
+	if b.Colors[mover]&b.Pieces[common.King]&hill != 0 {
+		return true, Outcome{winFor(mover), TerminationKingOfTheHill}
+	}
+
+	return false, Outcome{}
+}
+
+// insufficientMaterial is never the case as a lone king can still walk to
+// the hill.
+func (kingOfTheHill) insufficientMaterial(b board.Board) bool {
+	return false
+}
+
+func (kingOfTheHill) canWin(b board.Board, color uint) bool {
+	return true
+}
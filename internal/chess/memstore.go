@@ -0,0 +1,193 @@
+package chess
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoryStore keeps games in memory. Games are stored encoded the same way
+// as in mongo, so changing a game after storing or loading it never changes
+// the stored game. Games are lost when the process stops.
+type MemoryStore struct {
+	mu    sync.Mutex
+	games map[primitive.ObjectID][]byte
+}
+
+var _ GameStore = &MemoryStore{}
+
+// NewMemoryStore creates an empty store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{games: map[primitive.ObjectID][]byte{}}
+}
+
+// Create stores a new game.
+func (s *MemoryStore) Create(ctx context.Context, gm Game) error {
+	g := gm.(*game)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.games[g.Id]; ok {
+		return errors.Errorf("game %v already exists", g.ID())
+	}
+
+	g.schedule()
+	g.Version++
+	if err := s.put(g); err != nil {
+		g.Version--
+		return errors.Wrap(err, "inserting game")
+	}
+
+	return nil
+}
+
+// Get loads the game with an id.
+func (s *MemoryStore) Get(ctx context.Context, id string) (Game, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting object id")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	g, err := s.get(oid)
+	if err != nil {
+		return nil, err
+	}
+
+	return g, nil
+}
+
+// Update stores a changed game if the stored game is still at the version
+// it was loaded at.
+func (s *MemoryStore) Update(ctx context.Context, gm Game) error {
+	g := gm.(*game)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, err := s.get(g.Id)
+	if err == ErrGameNotFound || (err == nil && stored.Version != g.Version) {
+		return ErrConflict
+	}
+	if err != nil {
+		return err
+	}
+
+	g.schedule()
+	g.Version++
+	if err := s.put(g); err != nil {
+		g.Version--
+		return errors.Wrap(err, "saving game")
+	}
+
+	return nil
+}
+
+// Delete removes the game with an id.
+func (s *MemoryStore) Delete(ctx context.Context, id string) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errors.Wrap(err, "getting object id")
+	}
+
+	s.mu.Lock()
+	delete(s.games, oid)
+	s.mu.Unlock()
+
+	return nil
+}
+
+// List calls fn with every game matching the filter. The games are read
+// before fn is called so that fn can update them.
+func (s *MemoryStore) List(ctx context.Context, f GameFilter, fn func(Game) error) error {
+	s.mu.Lock()
+	var games []*game
+	for id := range s.games {
+		g, err := s.get(id)
+		if err != nil {
+			s.mu.Unlock()
+			return err
+		}
+		if f.matches(g) {
+			games = append(games, g)
+		}
+	}
+	s.mu.Unlock()
+
+	sortGames(games, f.Newest)
+	if f.Limit > 0 && len(games) > f.Limit {
+		games = games[:f.Limit]
+	}
+
+	for _, g := range games {
+		if err := ctx.Err(); err != nil {
+			return errors.Wrap(err, "finding games")
+		}
+		if err := fn(g); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// MarkSeen records that a player is currently following a game.
+func (s *MemoryStore) MarkSeen(ctx context.Context, id string, playerId string, now time.Time) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errors.Wrap(err, "getting object id")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	g, err := s.get(oid)
+	if err == ErrGameNotFound {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "marking player as seen")
+	}
+
+	if g.WhiteId == playerId {
+		g.WhiteSeen = now
+	}
+	if g.BlackId == playerId {
+		g.BlackSeen = now
+	}
+
+	return errors.Wrap(s.put(g), "marking player as seen")
+}
+
+// get decodes a stored game. The lock must be held.
+func (s *MemoryStore) get(id primitive.ObjectID) (*game, error) {
+	data, ok := s.games[id]
+	if !ok {
+		return nil, ErrGameNotFound
+	}
+
+	var g game
+	if err := bson.Unmarshal(data, &g); err != nil {
+		return nil, errors.Wrap(err, "decoding game")
+	}
+
+	return &g, nil
+}
+
+// put encodes and stores a game. The lock must be held.
+func (s *MemoryStore) put(g *game) error {
+	data, err := bson.Marshal(g)
+	if err != nil {
+		return errors.Wrap(err, "encoding game")
+	}
+	s.games[g.Id] = data
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package chess
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestUpdateConflict(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	g, err := StartGame(ctx, store, Player{Id: "white"}, Player{Id: "black"}, Options{}, time.Now())
+	if err != nil {
+		t.Fatalf("starting game: %v", err)
+	}
+
+	// Both requests load the game at the same version and play a move.
+	var loaded []Game
+	for _, move := range []string{"e4", "d4"} {
+		gm, err := FindById(ctx, store, g.ID(), Player{Id: "white"})
+		if err != nil {
+			t.Fatalf("finding game: %v", err)
+		}
+		if err := gm.Move(move, "white", time.Now()); err != nil {
+			t.Fatalf("playing %v: %v", move, err)
+		}
+		loaded = append(loaded, gm)
+	}
+
+	errs := make([]error, len(loaded))
+	var wg sync.WaitGroup
+	for i, gm := range loaded {
+		wg.Add(1)
+		go func(i int, gm Game) {
+			defer wg.Done()
+			errs[i] = store.Update(ctx, gm)
+		}(i, gm)
+	}
+	wg.Wait()
+
+	var saved, conflicts int
+	for _, err := range errs {
+		switch err {
+		case nil:
+			saved++
+		case ErrConflict:
+			conflicts++
+		default:
+			t.Fatalf("updating game: %v", err)
+		}
+	}
+	if saved != 1 || conflicts != 1 {
+		t.Fatalf("got %d saved and %d conflicts, want 1 of each", saved, conflicts)
+	}
+
+	stored, err := FindById(ctx, store, g.ID(), Player{})
+	if err != nil {
+		t.Fatalf("finding game: %v", err)
+	}
+	if stored.Ply() != 1 {
+		t.Errorf("got %d moves stored, want 1", stored.Ply())
+	}
+}
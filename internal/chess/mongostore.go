@@ -0,0 +1,258 @@
+package chess
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoStore keeps games in a mongo collection.
+type MongoStore struct {
+	coll *mongo.Collection
+}
+
+var _ GameStore = &MongoStore{}
+
+// NewMongoStore creates a store for the games in a collection.
+func NewMongoStore(coll *mongo.Collection) *MongoStore {
+	return &MongoStore{coll: coll}
+}
+
+// Create stores a new game.
+func (s *MongoStore) Create(ctx context.Context, gm Game) error {
+	g := gm.(*game)
+
+	g.schedule()
+	g.Version++
+	if _, err := s.coll.InsertOne(ctx, g); err != nil {
+		g.Version--
+		return errors.Wrap(err, "inserting game")
+	}
+
+	return nil
+}
+
+// Get loads the game with an id.
+func (s *MongoStore) Get(ctx context.Context, id string) (Game, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting object id")
+	}
+	filter := bson.D{primitive.E{Key: "_id", Value: oid}}
+
+	var g game
+	err = s.coll.FindOne(ctx, filter).Decode(&g)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrGameNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "retrieving game")
+	}
+
+	return &g, nil
+}
+
+// Update stores a changed game if the stored game is still at the version
+// it was loaded at.
+func (s *MongoStore) Update(ctx context.Context, gm Game) error {
+	g := gm.(*game)
+
+	filter := versionFilter(g)
+	g.schedule()
+	g.Version++
+
+	result, err := s.coll.ReplaceOne(ctx, filter, g)
+	if err != nil {
+		g.Version--
+		return errors.Wrap(err, "saving game")
+	}
+	if result.MatchedCount == 0 {
+		g.Version--
+		return ErrConflict
+	}
+
+	return nil
+}
+
+// Delete removes the game with an id.
+func (s *MongoStore) Delete(ctx context.Context, id string) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errors.Wrap(err, "getting object id")
+	}
+	filter := bson.D{primitive.E{Key: "_id", Value: oid}}
+
+	if _, err := s.coll.DeleteOne(ctx, filter); err != nil {
+		return errors.Wrap(err, "deleting game")
+	}
+
+	return nil
+}
+
+// versionFilter matches the stored game if it is still at the version the
+// game was loaded at. Games stored before they had versions have none.
+func versionFilter(g *game) bson.D {
+	version := interface{}(g.Version)
+	if g.Version == 0 {
+		version = bson.D{primitive.E{Key: "$in", Value: bson.A{0, nil}}}
+	}
+
+	return bson.D{primitive.E{Key: "_id", Value: g.Id}, primitive.E{Key: "version", Value: version}}
+}
+
+// List calls fn with every game matching the filter.
+func (s *MongoStore) List(ctx context.Context, f GameFilter, fn func(Game) error) error {
+	order := 1
+	if f.Newest {
+		order = -1
+	}
+	opts := options.Find().
+		SetSort(bson.D{primitive.E{Key: "date", Value: order}, primitive.E{Key: "_id", Value: order}}).
+		SetLimit(int64(f.Limit))
+
+	cur, err := s.coll.Find(ctx, listFilter(f), opts)
+	if err != nil {
+		return errors.Wrap(err, "finding games")
+	}
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		var g game
+		if err := cur.Decode(&g); err != nil {
+			return errors.Wrap(err, "decoding game")
+		}
+		if err := fn(&g); err != nil {
+			return err
+		}
+	}
+
+	return errors.Wrap(cur.Err(), "finding games")
+}
+
+// listFilter is the query for the games a filter picks. Games stored before
+// they had a variant are standard games, games without a rating are casual
+// and games without a visibility are public.
+func listFilter(f GameFilter) bson.D {
+	var and bson.A
+	is := func(key string, value interface{}) {
+		and = append(and, bson.D{primitive.E{Key: key, Value: value}})
+	}
+
+	if f.Player != "" {
+		is("$or", bson.A{
+			bson.D{primitive.E{Key: "whiteid", Value: f.Player}},
+			bson.D{primitive.E{Key: "blackid", Value: f.Player}},
+		})
+	}
+	if len(f.Status) > 0 {
+		is("status", bson.D{primitive.E{Key: "$in", Value: f.Status}})
+	}
+	if f.Result != "" {
+		is("result", f.Result)
+	}
+	if f.Variant == VariantStandard {
+		is("variant", bson.D{primitive.E{Key: "$in", Value: bson.A{VariantStandard, "", nil}}})
+	} else if f.Variant != "" {
+		is("variant", f.Variant)
+	}
+	if !f.From.IsZero() {
+		is("date", bson.D{primitive.E{Key: "$gte", Value: f.From}})
+	}
+	if !f.To.IsZero() {
+		is("date", bson.D{primitive.E{Key: "$lt", Value: f.To}})
+	}
+	if f.Rated != nil && *f.Rated {
+		is("rated", true)
+	} else if f.Rated != nil {
+		is("rated", bson.D{primitive.E{Key: "$ne", Value: true}})
+	}
+	if f.Viewer != "" {
+		is("$or", bson.A{
+			bson.D{primitive.E{Key: "visibility", Value: bson.D{primitive.E{Key: "$in", Value: bson.A{VisibilityPublic, "", nil}}}}},
+			bson.D{primitive.E{Key: "whiteid", Value: f.Viewer}},
+			bson.D{primitive.E{Key: "blackid", Value: f.Viewer}},
+		})
+	}
+	if !f.Due.IsZero() {
+		due := bson.A{
+			bson.D{primitive.E{Key: "flagat", Value: bson.D{primitive.E{Key: "$lte", Value: f.Due}}}},
+			bson.D{
+				primitive.E{Key: "movedat", Value: bson.D{primitive.E{Key: "$exists", Value: false}}},
+				primitive.E{Key: "imported", Value: bson.D{primitive.E{Key: "$ne", Value: true}}},
+				primitive.E{Key: "status", Value: StatusInProgress},
+			},
+		}
+		if f.Idle > 0 {
+			due = append(due, bson.D{primitive.E{Key: "movedat", Value: bson.D{primitive.E{Key: "$lte", Value: f.Due.Add(-f.Idle)}}}})
+		}
+		is("$or", due)
+	}
+	if c := f.After; c != nil {
+		op := "$gt"
+		if f.Newest {
+			op = "$lt"
+		}
+		is("$or", bson.A{
+			bson.D{primitive.E{Key: "date", Value: bson.D{primitive.E{Key: op, Value: c.Date}}}},
+			bson.D{primitive.E{Key: "date", Value: c.Date}, primitive.E{Key: "_id", Value: bson.D{primitive.E{Key: op, Value: c.Id}}}},
+		})
+	}
+
+	if len(and) == 0 {
+		return bson.D{}
+	}
+
+	return bson.D{primitive.E{Key: "$and", Value: and}}
+}
+
+// EnsureIndexes creates the indexes listing games needs. Creating an index
+// that already exists does nothing.
+func (s *MongoStore) EnsureIndexes(ctx context.Context) error {
+	byDate := func(keys ...string) mongo.IndexModel {
+		var d bson.D
+		for _, key := range keys {
+			d = append(d, primitive.E{Key: key, Value: 1})
+		}
+		d = append(d, primitive.E{Key: "date", Value: -1}, primitive.E{Key: "_id", Value: -1})
+		return mongo.IndexModel{Keys: d}
+	}
+
+	_, err := s.coll.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		byDate(),
+		byDate("whiteid"),
+		byDate("blackid"),
+		byDate("status"),
+		byDate("variant"),
+		byDate("result"),
+		byDate("rated"),
+		{Keys: bson.D{primitive.E{Key: "flagat", Value: 1}}},
+		{Keys: bson.D{primitive.E{Key: "movedat", Value: 1}}},
+	})
+
+	return errors.Wrap(err, "creating game indexes")
+}
+
+// MarkSeen records that a player is currently following a game.
+func (s *MongoStore) MarkSeen(ctx context.Context, id string, playerId string, now time.Time) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errors.Wrap(err, "getting object id")
+	}
+
+	seats := []struct{ id, seen string }{{"whiteid", "whiteseen"}, {"blackid", "blackseen"}}
+	for _, seat := range seats {
+		filter := bson.D{primitive.E{Key: "_id", Value: oid}, primitive.E{Key: seat.id, Value: playerId}}
+		update := bson.D{primitive.E{Key: "$set", Value: bson.D{primitive.E{Key: seat.seen, Value: now}}}}
+
+		if _, err := s.coll.UpdateOne(ctx, filter, update); err != nil {
+			return errors.Wrap(err, "marking player as seen")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,30 @@
+package chess
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestVersionFilter(t *testing.T) {
+	id := primitive.NewObjectID()
+
+	tests := []struct {
+		name    string
+		version int
+		filter  bson.D
+	}{
+		{"saved game", 3, bson.D{{Key: "_id", Value: id}, {Key: "version", Value: 3}}},
+		{"game stored without a version", 0, bson.D{{Key: "_id", Value: id}, {Key: "version", Value: bson.D{{Key: "$in", Value: bson.A{0, nil}}}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := versionFilter(&game{Id: id, Version: tt.version}); !reflect.DeepEqual(got, tt.filter) {
+				t.Errorf("got %v, want %v", got, tt.filter)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package chess
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/schafer14/MtM/board"
+	"github.com/schafer14/MtM/common"
+	"github.com/schafer14/MtM/move"
+)
+
+// Promotions are written by adding the letter of the new piece to the move
+// in every notation. The letter may be upper or lower case.
+//
+//	UCI:                e7e8q, e7e8N
+//	Long algebraic:     e7-e8=Q, e7xd8=N+, e7-e8Q
+//	Standard algebraic: e8=Q, exd8=N+, e8Q
+//
+// A move onto the last rank by a pawn that leaves out the piece is rejected
+// with ErrPromotionRequired rather than promoting to a queen.
+var (
+	// uciPattern matches moves in UCI notation like e2e4 or e7e8q.
+	uciPattern = regexp.MustCompile(`^([a-h][1-8])([a-h][1-8])([a-zA-Z])?$`)
+
+	// lanPattern matches moves in long algebraic notation like Ng1-f3 or
+	// e7xd8=Q+.
+	lanPattern = regexp.MustCompile(`^([PNBRQK])?([a-h][1-8])[-x]([a-h][1-8])(?:=?([a-zA-Z]))?[+#]?[!?]*$`)
+)
+
+var (
+	// ErrPromotionRequired is returned for a pawn moving onto the last rank
+	// without saying which piece it promotes to.
+	ErrPromotionRequired = errors.New("a promotion piece is required: add q, r, b or n to the move")
+
+	// ErrInvalidPromotion is returned for promotions to a piece other than a
+	// queen, rook, bishop or knight.
+	ErrInvalidPromotion = errors.New("pawns can only promote to a queen, rook, bishop or knight")
+)
+
+// ParseMove finds the legal move written in UCI, long algebraic or standard
+// algebraic notation. Crazyhouse drops are written as N@f3 in all of them.
+func ParseMove(b board.Board, text string) (move.Move32, error) {
+	return parseMove(newPosition(b, standard{}), text)
+}
+
+// parseMove finds the legal move in a position written in any of the
+// supported notations.
+func parseMove(p position, text string) (move.Move32, error) {
+	text = strings.TrimSpace(text)
+
+	if parts := dropPattern.FindStringSubmatch(text); parts != nil {
+		return parseDrop(p, parts[1], parts[2])
+	}
+
+	if parts := uciPattern.FindStringSubmatch(text); parts != nil {
+		return findMove(p, parts[1], parts[2], parts[3])
+	}
+
+	if parts := lanPattern.FindStringSubmatch(text); parts != nil {
+		piece := common.Pawn
+		if parts[1] != "" {
+			piece = uint(strings.Index("PNBRQK", parts[1]))
+		}
+
+		m, err := findMove(p, parts[2], parts[3], parts[4])
+		if err == nil && parts[1] != "" && m.Piece() != piece {
+			return 0, ErrIllegalMove
+		}
+		return m, err
+	}
+
+	return parseSAN(p, text)
+}
+
+// findMove finds the legal move between two squares promoting to the given
+// piece if it is a promotion. Chess960 castling is written as the king
+// moving onto its rook, or as the king moving to where it ends up when that
+// is not a move of its own.
+func findMove(p position, src string, dest string, promo string) (move.Move32, error) {
+	srcNum, destNum := squareNum(src), squareNum(dest)
+	promo, err := promotionLetter(promo)
+	if err != nil {
+		return 0, err
+	}
+
+	var found []move.Move32
+	for _, m := range p.moves() {
+		if m.Src() == srcNum && m.Dest() == destNum && !isDrop(m) {
+			found = append(found, m)
+		}
+	}
+
+	if len(found) == 0 {
+		for _, m := range p.castles() {
+			_, kingSide := m.Castle()
+			if kingDest, _ := castleSquares(p.board.Turn, kingSide); m.Src() == srcNum && kingDest == destNum {
+				found = append(found, m)
+			}
+		}
+	}
+
+	return matchPromotion(found, promo)
+}
+
+// promotionLetter checks the letter of a promotion piece and returns it in
+// upper case.
+func promotionLetter(letter string) (string, error) {
+	letter = strings.ToUpper(letter)
+	switch letter {
+	case "", "N", "B", "R", "Q":
+		return letter, nil
+	}
+
+	return "", ErrInvalidPromotion
+}
+
+// matchPromotion picks the move promoting to the piece from moves that only
+// differ in their promotion. An empty piece picks the move that is not a
+// promotion.
+func matchPromotion(moves []move.Move32, promo string) (move.Move32, error) {
+	if len(moves) == 0 {
+		return 0, ErrIllegalMove
+	}
+
+	for _, m := range moves {
+		isPromo, promoPiece := m.Promotion()
+		if isPromo && pieceLetters[promoPiece] == promo {
+			return m, nil
+		}
+		if !isPromo && promo == "" {
+			return m, nil
+		}
+	}
+
+	if isPromo, _ := moves[0].Promotion(); isPromo && promo == "" {
+		return 0, ErrPromotionRequired
+	}
+
+	return 0, ErrIllegalMove
+}
@@ -0,0 +1,147 @@
+package chess
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/schafer14/MtM/board"
+)
+
+func TestParseMovePromotion(t *testing.T) {
+	b := board.FromFen("k7/4P3/8/8/8/8/8/4K3 w - - 0 1")
+
+	tests := []struct {
+		name  string
+		text  string
+		promo string
+		err   error
+	}{
+		{"uci without piece", "e7e8", "", ErrPromotionRequired},
+		{"uci", "e7e8n", "N", nil},
+		{"long algebraic", "e7-e8=R", "R", nil},
+		{"san", "e8=B", "B", nil},
+		{"san without piece", "e8", "", ErrPromotionRequired},
+		{"san to a king", "e8=K", "", ErrInvalidPromotion},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := ParseMove(b, tt.text)
+			if errors.Cause(err) != tt.err {
+				t.Fatalf("parsing %v: got error %v, want %v", tt.text, err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+
+			isPromo, piece := m.Promotion()
+			if !isPromo || pieceLetters[piece] != tt.promo {
+				t.Errorf("parsing %v: got %v, want a promotion to %v", tt.text, m, tt.promo)
+			}
+		})
+	}
+}
+
+const (
+	// disambiguationRooks has rooks on a1, a5 and h1 so rook moves need the
+	// file or the rank of the rook.
+	disambiguationRooks = "4k3/8/8/R7/8/8/4K3/R6R w - - 0 1"
+
+	// disambiguationQueens has queens on a1, c1 and a3 that can all move to
+	// b2 so some queen moves need the whole square.
+	disambiguationQueens = "4k3/8/8/8/8/Q7/8/Q1Q1K3 w - - 0 1"
+
+	castlingPosition = "r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1"
+	openGamePosition = "rnbqkbnr/ppp1pppp/8/3p4/4P3/8/PPPP1PPP/RNBQKBNR w KQkq - 0 2"
+)
+
+func TestParseMove(t *testing.T) {
+	start := "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+
+	tests := []struct {
+		name string
+		fen  string
+		text string
+		uci  string
+		err  error
+	}{
+		{"uci", start, "e2e4", "e2e4", nil},
+		{"long algebraic pawn move", start, "e2-e4", "e2e4", nil},
+		{"long algebraic piece move", start, "Ng1-f3", "g1f3", nil},
+		{"long algebraic with the wrong piece", start, "Bg1-f3", "", ErrIllegalMove},
+		{"long algebraic capture", openGamePosition, "e4xd5", "e4d5", nil},
+		{"san pawn move", start, "e4", "e2e4", nil},
+		{"san piece move", start, "Nf3", "g1f3", nil},
+		{"san with suffixes", start, "Nf3+!?", "g1f3", nil},
+		{"san pawn capture", openGamePosition, "exd5", "e4d5", nil},
+		{"illegal move", start, "e5", "", ErrIllegalMove},
+		{"not a move", start, "e9", "", ErrInvalidMove},
+		{"surrounding space", start, " e4 ", "e2e4", nil},
+		{"rook by file", disambiguationRooks, "Rad1", "a1d1", nil},
+		{"other rook by file", disambiguationRooks, "Rhd1", "h1d1", nil},
+		{"rook without file", disambiguationRooks, "Rd1", "", ErrAmbiguousMove},
+		{"rook by rank", disambiguationRooks, "R1a3", "a1a3", nil},
+		{"other rook by rank", disambiguationRooks, "R5a3", "a5a3", nil},
+		{"rook without rank", disambiguationRooks, "Ra3", "", ErrAmbiguousMove},
+		{"queen by square", disambiguationQueens, "Qa1b2", "a1b2", nil},
+		{"queen by file", disambiguationQueens, "Qcb2", "c1b2", nil},
+		{"queen by rank", disambiguationQueens, "Q3b2", "a3b2", nil},
+		{"queen by shared file", disambiguationQueens, "Qab2", "", ErrAmbiguousMove},
+		{"queen by shared rank", disambiguationQueens, "Q1b2", "", ErrAmbiguousMove},
+		{"castling king side", castlingPosition, "O-O", "e1g1", nil},
+		{"castling queen side with zeros", castlingPosition, "0-0-0", "e1c1", nil},
+		{"castling in uci", castlingPosition, "e1g1", "e1g1", nil},
+		{"castling that is not allowed", start, "O-O", "", ErrIllegalMove},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := ParseMove(board.FromFen(tt.fen), tt.text)
+			if errors.Cause(err) != tt.err {
+				t.Fatalf("parsing %v: got error %v, want %v", tt.text, err, tt.err)
+			}
+			if err == nil && m.String() != tt.uci {
+				t.Errorf("parsing %v: got %v, want %v", tt.text, m, tt.uci)
+			}
+		})
+	}
+}
+
+func TestWriteSAN(t *testing.T) {
+	tests := []struct {
+		name string
+		fen  string
+		uci  string
+		san  string
+	}{
+		{"pawn move", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "e2e4", "e4"},
+		{"piece move", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "g1f3", "Nf3"},
+		{"pawn capture", openGamePosition, "e4d5", "exd5"},
+		{"piece capture", "rnbqkbnr/ppp1pppp/8/3p4/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - 0 2", "d5e4", "dxe4"},
+		{"rook by file", disambiguationRooks, "a1d1", "Rad1"},
+		{"rook by rank", disambiguationRooks, "a5a3", "R5a3"},
+		{"only rook", disambiguationRooks, "a5b5", "Rb5"},
+		{"queen by square", disambiguationQueens, "a1b2", "Qa1b2"},
+		{"queen by file", disambiguationQueens, "c1b2", "Qcb2"},
+		{"queen by rank", disambiguationQueens, "a3b2", "Q3b2"},
+		{"castling king side", castlingPosition, "e1g1", "O-O"},
+		{"castling queen side", castlingPosition, "e1c1", "O-O-O"},
+		{"check", "rnbqkbnr/ppppp1pp/5p2/8/4P3/8/PPPP1PPP/RNBQKBNR w KQkq - 0 2", "d1h5", "Qh5+"},
+		{"mate", "rnbqkbnr/pppp1ppp/8/4p3/6P1/5P2/PPPPP2P/RNBQKBNR b KQkq - 0 2", "d8h4", "Qh4#"},
+		{"promotion with check", "1r2k3/P7/8/8/8/8/8/4K3 w - - 0 1", "a7b8q", "axb8=Q+"},
+		{"underpromotion", "1r2k3/P7/8/8/8/8/8/4K3 w - - 0 1", "a7a8n", "a8=N"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := board.FromFen(tt.fen)
+			m, err := ParseMove(b, tt.uci)
+			if err != nil {
+				t.Fatalf("parsing %v: %v", tt.uci, err)
+			}
+			if san := SAN(b, m); san != tt.san {
+				t.Errorf("got %v, want %v", san, tt.san)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package chess
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/schafer14/MtM/common"
+	"github.com/schafer14/MtM/move"
+)
+
+// pgnTerminations maps the reason a game ended onto the values of the PGN
+// Termination tag.
+var pgnTerminations = map[Termination]string{
+	TerminationTimeout:                     "time forfeit",
+	TerminationTimeoutInsufficientMaterial: "time forfeit",
+	TerminationAbandoned:                   "abandoned",
+	TerminationAborted:                     "abandoned",
+}
+
+// pgnVariants are the values of the PGN Variant tag for games that are not
+// standard chess.
+var pgnVariants = map[string]string{
+	VariantChess960:      "Chess960",
+	VariantKingOfTheHill: "King of the Hill",
+	VariantThreeCheck:    "Three-check",
+	VariantRacingKings:   "Racing Kings",
+	VariantCrazyhouse:    "Crazyhouse",
+}
+
+// pgnLineLength is the longest line of movetext written in a PGN.
+const pgnLineLength = 79
+
+// WritePGN writes the game in portable game notation.
+func (g *game) WritePGN(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	for _, tag := range g.pgnTags() {
+		fmt.Fprintf(bw, "[%s \"%s\"]\n", tag[0], pgnEscape(tag[1]))
+	}
+	bw.WriteString("\n")
+
+	var clocks []time.Duration
+	if g.TimeControl != nil && len(g.MoveTimes) == len(g.Moves) {
+		clocks = g.moveClocks()
+	}
+
+	var tokens []string
+	ply := 0
+	g.replay(func(h *history, m move.Move32) {
+		turn := h.pos.board.Turn
+		if ply == 0 || turn == common.White {
+			number := fmt.Sprintf("%d.", h.fullmove)
+			if turn == common.Black {
+				number += ".."
+			}
+			tokens = append(tokens, number)
+		}
+		tokens = append(tokens, writeSAN(h.pos, m))
+		if clocks != nil {
+			tokens = append(tokens, fmt.Sprintf("{[%%clk %s]}", pgnClock(clocks[ply])))
+		}
+
+		ply++
+	})
+	tokens = append(tokens, string(g.pgnResult()))
+
+	line := 0
+	for i, token := range tokens {
+		if i > 0 {
+			if line+1+len(token) > pgnLineLength {
+				bw.WriteString("\n")
+				line = 0
+			} else {
+				bw.WriteString(" ")
+				line++
+			}
+		}
+		bw.WriteString(token)
+		line += len(token)
+	}
+	bw.WriteString("\n\n")
+
+	return errors.Wrap(bw.Flush(), "writing pgn")
+}
+
+// pgnTags are the tag pairs of the game starting with the seven tag roster.
+func (g *game) pgnTags() [][2]string {
+	white, black := g.White, g.Black
+	if white == "" {
+		white = "Unknown"
+	}
+	if black == "" {
+		black = "Unknown"
+	}
+
+	tags := [][2]string{
+		{"Event", "Casual game"},
+		{"Site", "chess-serve"},
+		{"Date", g.Date.Format("2006.01.02")},
+		{"Round", "-"},
+		{"White", white},
+		{"Black", black},
+		{"Result", string(g.pgnResult())},
+	}
+
+	// PGN has no notation for delays so they are written after a d, as in
+	// 300d5.
+	if tc := g.TimeControl; tc != nil {
+		control := fmt.Sprintf("%d", tc.Base)
+		if tc.Increment > 0 {
+			control += fmt.Sprintf("+%d", tc.Increment)
+		}
+		if tc.Delay > 0 {
+			control += fmt.Sprintf("d%d", tc.Delay)
+		}
+		tags = append(tags, [2]string{"TimeControl", control})
+	} else {
+		tags = append(tags, [2]string{"TimeControl", "-"})
+	}
+
+	termination := "unterminated"
+	if g.IsOver() {
+		termination = "normal"
+		if t, ok := pgnTerminations[g.Termination]; ok {
+			termination = t
+		}
+	}
+	tags = append(tags, [2]string{"Termination", termination})
+
+	if variant, ok := pgnVariants[g.Variant]; ok {
+		tags = append(tags, [2]string{"Variant", variant})
+	}
+	if g.StartFen != "" {
+		tags = append(tags, [2]string{"SetUp", "1"}, [2]string{"FEN", g.start().fen()})
+	}
+
+	return tags
+}
+
+// pgnResult is the result written in the Result tag and after the moves.
+// Games stored before results were kept have none.
+func (g *game) pgnResult() Result {
+	if g.Result == "" {
+		return ResultNone
+	}
+
+	return g.Result
+}
+
+// pgnEscape escapes the characters that can not appear in a tag value.
+func pgnEscape(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	return strings.ReplaceAll(value, `"`, `\"`)
+}
+
+// pgnClock formats a clock in the h:mm:ss format used by clock comments.
+func pgnClock(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	d = d.Round(time.Second)
+
+	return fmt.Sprintf("%d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+}
@@ -0,0 +1,59 @@
+package chess
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestPGNTags(t *testing.T) {
+	tests := []struct {
+		name string
+		tc   *TimeControl
+		tag  string
+	}{
+		{"untimed", nil, `[TimeControl "-"]`},
+		{"sudden death", &TimeControl{Base: 300}, `[TimeControl "300"]`},
+		{"increment", &TimeControl{Base: 300, Increment: 5}, `[TimeControl "300+5"]`},
+		{"delay", &TimeControl{Base: 300, Delay: 2}, `[TimeControl "300d2"]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := NewGame(primitive.NewObjectID(), time.Now(), Player{Id: "white", Name: "Alice"}, Options{TimeControl: tt.tc})
+			if err != nil {
+				t.Fatalf("creating game: %v", err)
+			}
+
+			var b strings.Builder
+			if err := g.WritePGN(&b); err != nil {
+				t.Fatalf("writing pgn: %v", err)
+			}
+			if !strings.Contains(b.String(), tt.tag+"\n") {
+				t.Errorf("got\n%v\nwant a %v tag", b.String(), tt.tag)
+			}
+		})
+	}
+}
+
+func TestPGNStoredWithoutPlayersOrResult(t *testing.T) {
+	g := startVariant(t, VariantStandard, "")
+	play(t, g, "e4")
+
+	// Games stored before results were kept have neither a result nor names
+	// for guests.
+	g.White, g.Black, g.Result = "", "", ""
+
+	var b strings.Builder
+	if err := g.WritePGN(&b); err != nil {
+		t.Fatalf("writing pgn: %v", err)
+	}
+
+	for _, want := range []string{`[White "Unknown"]`, `[Black "Unknown"]`, `[Result "*"]`, "1. e4 *\n"} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("got\n%v\nwant %v", b.String(), want)
+		}
+	}
+}
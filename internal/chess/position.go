@@ -0,0 +1,244 @@
+package chess
+
+import (
+	"math/bits"
+	"strings"
+
+	"github.com/schafer14/MtM/board"
+	"github.com/schafer14/MtM/common"
+	"github.com/schafer14/MtM/move"
+)
+
+// position is a position of a game in a variant. The MtM board only knows
+// the castling rules of standard chess, so in Chess960 games the board is
+// kept without any castling rights and castling is handled here instead. The
+// same goes for the pockets of Crazyhouse games.
+type position struct {
+	board   board.Board
+	variant Variant
+
+	// rooks are the rooks that can still castle in a Chess960 game.
+	rooks uint64
+
+	// pockets count the pieces of each type each color can drop in a
+	// Crazyhouse game and promoted are the pieces that were promoted from
+	// pawns.
+	pockets  [2][5]int
+	promoted uint64
+}
+
+func newPosition(b board.Board, v Variant) position {
+	return position{board: b, variant: v}
+}
+
+// moves lists all the moves the variant allows in the position.
+func (p position) moves() []move.Move32 {
+	var moves []move.Move32
+	for _, m := range append(append(legalMoves(p.board), p.castles()...), p.drops()...) {
+		if p.variant.allows(p, m) {
+			moves = append(moves, m)
+		}
+	}
+
+	return moves
+}
+
+// play plays a legal move on the position.
+func (p *position) play(m move.Move32) {
+	if p.variant.pockets() {
+		p.pocket(m)
+	}
+	if isDrop(m) {
+		// The piece is already on the board. Moving it onto its own square
+		// clears the en passant square and passes the turn.
+		p.board.Move(m)
+		return
+	}
+	if !p.variant.chess960() {
+		p.board.Move(m)
+		return
+	}
+
+	// Moving the king gives up both castling rights, moving or losing a rook
+	// gives up the right to castle with it.
+	color := p.board.Turn
+	if m.Piece() == common.King {
+		p.rooks &^= backRank(color)
+	}
+	p.rooks &^= 1<<m.Src() | 1<<m.Dest()
+
+	isCastle, kingSide := m.Castle()
+	if !isCastle {
+		p.board.Move(m)
+		return
+	}
+
+	// The rook is taken off first as the king can land on its square. Moving
+	// the king as a normal move lets the board clear the en passant square
+	// and pass the turn.
+	kingDest, rookDest := castleSquares(color, kingSide)
+	p.board.Pieces[common.Rook] &^= 1 << m.Dest()
+	p.board.Colors[color] &^= 1 << m.Dest()
+	p.board.Move(move.New(common.King, m.Src(), kingDest))
+	p.board.Pieces[common.Rook] |= 1 << rookDest
+	p.board.Colors[color] |= 1 << rookDest
+}
+
+// castles lists the legal Chess960 castling moves. They are written as the
+// king taking its own rook, the way UCI writes Chess960 castling.
+func (p position) castles() []move.Move32 {
+	b := p.board
+	if !p.variant.chess960() || b.IsInCheck(b.Turn) {
+		return nil
+	}
+
+	color := b.Turn
+	own := b.Colors[color]
+	all := b.Colors[common.White] | b.Colors[common.Black]
+	king := uint(bits.TrailingZeros64(own & b.Pieces[common.King]))
+
+	var moves []move.Move32
+	for rooks := p.rooks & own & b.Pieces[common.Rook]; rooks != 0; rooks &= rooks - 1 {
+		rook := uint(bits.TrailingZeros64(rooks))
+		kingSide := rook > king
+		kingDest, rookDest := castleSquares(color, kingSide)
+
+		// Every square the king and rook cross must be empty apart from the
+		// king and rook themselves.
+		if all&^(1<<king|1<<rook)&(span(king, kingDest)|span(rook, rookDest)) != 0 {
+			continue
+		}
+		if !p.safePath(king, kingDest, rook) {
+			continue
+		}
+
+		m := move.New(common.King, king, rook)
+		if kingSide {
+			m = m.SetCastleKing()
+		} else {
+			m = m.SetCastleQueen()
+		}
+		moves = append(moves, m)
+	}
+
+	return moves
+}
+
+// safePath checks that none of the squares the king crosses while castling,
+// including where it starts and ends, are attacked.
+func (p position) safePath(king, kingDest, rook uint) bool {
+	color := p.board.Turn
+
+	for path := span(king, kingDest); path != 0; path &= path - 1 {
+		test := p.board
+		test.Pieces[common.Rook] &^= 1 << rook
+		test.Colors[color] &^= 1<<rook | 1<<king
+		test.Pieces[common.King] &^= 1 << king
+
+		square := uint64(1) << uint(bits.TrailingZeros64(path))
+		test.Pieces[common.King] |= square
+		test.Colors[color] |= square
+		if test.IsInCheck(color) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// fenFields are the piece placement, side to move, castling rights and en
+// passant fields of the FEN of the position. Chess960 castling rights are
+// written as in X-FEN and Crazyhouse pockets as in the FEN used by lichess.
+func (p position) fenFields() []string {
+	fields := strings.Fields(p.board.String())[:4]
+	if p.variant.chess960() {
+		fields[2] = p.castlingField()
+	}
+	if p.variant.pockets() {
+		fields[0] = p.pocketPlacement(fields[0])
+	}
+
+	return fields
+}
+
+// castlingField writes Chess960 castling rights in X-FEN. A right is written
+// as K or Q when it is for the outermost rook on that side of the king and
+// by the file of the rook otherwise.
+func (p position) castlingField() string {
+	var field string
+
+	for _, color := range []uint{common.White, common.Black} {
+		own := p.board.Colors[color]
+		rooks := own & p.board.Pieces[common.Rook] & backRank(color)
+		king := uint(bits.TrailingZeros64(own & p.board.Pieces[common.King]))
+
+		var rights string
+		for r := p.rooks & rooks; r != 0; r &= r - 1 {
+			rook := uint(bits.TrailingZeros64(r))
+
+			right := string('A' + rune(rook%8))
+			switch {
+			case rook > king && rooks>>(rook+1) == 0:
+				right = "K"
+			case rook < king && rooks&(1<<rook-1) == 0:
+				right = "Q"
+			}
+
+			// King side rights are written first.
+			if rook > king {
+				rights = right + rights
+			} else {
+				rights += right
+			}
+		}
+
+		if color == common.Black {
+			rights = strings.ToLower(rights)
+		}
+		field += rights
+	}
+
+	if field == "" {
+		return "-"
+	}
+
+	return field
+}
+
+// castleSquares are the squares the king and rook end up on after castling.
+// They are the same as in standard chess.
+func castleSquares(color uint, kingSide bool) (uint, uint) {
+	base := uint(0)
+	if color == common.Black {
+		base = 56
+	}
+	if kingSide {
+		return base + 6, base + 5
+	}
+
+	return base + 2, base + 3
+}
+
+// backRank is a bitboard of the rank a color starts on.
+func backRank(color uint) uint64 {
+	if color == common.Black {
+		return common.Row8
+	}
+
+	return common.Row1
+}
+
+// span is a bitboard of the squares from one square to another on the same
+// rank, including both.
+func span(from, to uint) uint64 {
+	if from > to {
+		from, to = to, from
+	}
+
+	var squares uint64
+	for s := from; s <= to; s++ {
+		squares |= 1 << s
+	}
+
+	return squares
+}
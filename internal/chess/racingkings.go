@@ -0,0 +1,72 @@
+package chess
+
+import (
+	"github.com/schafer14/MtM/board"
+	"github.com/schafer14/MtM/common"
+	"github.com/schafer14/MtM/move"
+)
+
+const TerminationEighthRank Termination = "king reached the eighth rank"
+
+// racingKingsFen is the position games of Racing Kings start from.
+const racingKingsFen = "8/8/8/8/8/8/krbnNBRK/qrbnNBRQ w - - 0 1"
+
+// racingKings is a race to get a king to the eighth rank. Giving check is
+// not allowed. When white gets there first black has one move to draw by
+// also getting there.
+type racingKings struct {
+	standard
+}
+
+func (racingKings) Name() string {
+	return VariantRacingKings
+}
+
+func (racingKings) start(opts Options) (string, error) {
+	if opts.Fen != "" {
+		return opts.Fen, nil
+	}
+
+	return racingKingsFen, nil
+}
+
+// allows forbids moves that give check.
+func (racingKings) allows(p position, m move.Move32) bool {
+	p.play(m)
+	return !p.board.IsInCheck(p.board.Turn)
+}
+
+func (racingKings) outcome(h *history) (bool, Outcome) {
+	b := h.pos.board
+	white := b.Colors[common.White]&b.Pieces[common.King]&common.Row8 != 0
+	black := b.Colors[common.Black]&b.Pieces[common.King]&common.Row8 != 0
+
+	switch {
+	case white && black:
+		return true, Outcome{ResultDraw, TerminationEighthRank}
+	case black:
+		return true, Outcome{ResultBlackWins, TerminationEighthRank}
+	case !white:
+		return false, Outcome{}
+	}
+
+	// Black gets one more move when white reaches the eighth rank first.
+	if b.Turn == common.Black {
+		for _, m := range h.pos.moves() {
+			if m.Piece() == common.King && m.Dest() >= 56 {
+				return false, Outcome{}
+			}
+		}
+	}
+
+	return true, Outcome{ResultWhiteWins, TerminationEighthRank}
+}
+
+// insufficientMaterial is never the case as the kings can always race.
+func (racingKings) insufficientMaterial(b board.Board) bool {
+	return false
+}
+
+func (racingKings) canWin(b board.Board, color uint) bool {
+	return true
+}
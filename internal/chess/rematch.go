@@ -0,0 +1,63 @@
+package chess
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	// ErrNotOver is returned when asking for a rematch of a game that is
+	// still being played.
+	ErrNotOver = errors.New("game is not over")
+
+	// ErrRematchStarted is returned when asking for a rematch of a game that
+	// already has one.
+	ErrRematchStarted = errors.New("rematch has already started")
+)
+
+// Rematch offers the opponent a rematch or accepts the rematch the opponent
+// offered. Accepting returns the new game with the given id, which has the
+// settings of this game with the colors swapped and still has to be stored.
+// Offering returns no game.
+func (g *game) Rematch(playerId string, id primitive.ObjectID, now time.Time) (Game, error) {
+	if !g.IsOver() {
+		return nil, ErrNotOver
+	}
+	if g.WhiteId == "" || g.BlackId == "" {
+		return nil, ErrNotStarted
+	}
+	if g.RematchId != "" {
+		return nil, ErrRematchStarted
+	}
+	color, err := g.colorOf(playerId)
+	if err != nil {
+		return nil, err
+	}
+
+	if g.RematchOffer != colorName(opponent(color)) {
+		g.record(Event{Type: EventRematchOffered, Time: now, ActorId: playerId, Color: colorName(color)})
+		return nil, nil
+	}
+
+	opts := Options{
+		TimeControl: g.TimeControl,
+		Fen:         g.StartFen,
+		Variant:     g.Variant,
+		Rated:       g.Rated,
+		Visibility:  g.Visibility,
+		RematchOf:   g.ID(),
+	}
+	rematch, err := NewGame(id, now, Player{Id: g.BlackId, Name: g.Black}, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := rematch.Join(Player{Id: g.WhiteId, Name: g.White}, now); err != nil {
+		return nil, err
+	}
+
+	g.record(Event{Type: EventRematchAccepted, Time: now, ActorId: playerId, Color: colorName(color), GameId: rematch.ID()})
+
+	return rematch, nil
+}
@@ -0,0 +1,59 @@
+package chess
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestRematch(t *testing.T) {
+	now := time.Now()
+	g := startVariant(t, "", "")
+
+	if _, err := g.Rematch("white", primitive.NewObjectID(), now); err != ErrNotOver {
+		t.Errorf("asking for a rematch of a game in progress: got error %v, want %v", err, ErrNotOver)
+	}
+	if err := g.Resign("black", now); err != nil {
+		t.Fatalf("resigning: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		player string
+		err    error
+		offer  string
+	}{
+		{"asking as a spectator", "guest", ErrNotParticipant, ""},
+		{"offering", "white", nil, "white"},
+		{"offering again", "white", nil, "white"},
+	}
+
+	for _, tt := range tests {
+		rematch, err := g.Rematch(tt.player, primitive.NewObjectID(), now)
+		if err != tt.err || rematch != nil || g.RematchOffer != tt.offer {
+			t.Errorf("%v: got %v with error %v and offer %q, want no game with error %v and offer %q", tt.name, rematch, err, g.RematchOffer, tt.err, tt.offer)
+		}
+	}
+
+	id := primitive.NewObjectID()
+	gm, err := g.Rematch("black", id, now)
+	if err != nil {
+		t.Fatalf("accepting rematch: %v", err)
+	}
+	rematch := gm.(*game)
+
+	if rematch.Id != id || rematch.RematchOf != g.ID() || g.RematchId != rematch.ID() {
+		t.Errorf("got rematch %v of %q linked from %q, want %v of %v linked both ways", rematch.ID(), rematch.RematchOf, g.RematchId, id.Hex(), g.ID())
+	}
+	if rematch.WhiteId != "black" || rematch.BlackId != "white" {
+		t.Errorf("got %v against %v, want the colors swapped", rematch.WhiteId, rematch.BlackId)
+	}
+	if rematch.Status != StatusInProgress || g.RematchOffer != "" {
+		t.Errorf("got rematch status %v and offer %q, want a rematch in progress and no offer", rematch.Status, g.RematchOffer)
+	}
+
+	if _, err := g.Rematch("white", primitive.NewObjectID(), now); err != ErrRematchStarted {
+		t.Errorf("asking for a second rematch: got error %v, want %v", err, ErrRematchStarted)
+	}
+}
@@ -0,0 +1,66 @@
+package chess
+
+import "github.com/schafer14/MtM/common"
+
+// Result is the score of a game written from whites perspective.
+type Result string
+
+const (
+	ResultNone      Result = "*"
+	ResultWhiteWins Result = "1-0"
+	ResultBlackWins Result = "0-1"
+	ResultDraw      Result = "1/2-1/2"
+)
+
+// Termination describes why a game finished.
+type Termination string
+
+const (
+	TerminationCheckmate Termination = "checkmate"
+	TerminationStalemate Termination = "stalemate"
+)
+
+// Outcome is the final result of a game and the reason it ended.
+type Outcome struct {
+	Result      Result      `json:"result"`
+	Termination Termination `json:"termination"`
+}
+
+// winFor returns the result where the given color wins.
+func winFor(color uint) Result {
+	if color == common.White {
+		return ResultWhiteWins
+	}
+	return ResultBlackWins
+}
+
+// terminal checks if the side to move has no legal moves left. A side with
+// no moves that is in check has been mated, otherwise the game is a
+// stalemate.
+func terminal(p position) (bool, Outcome) {
+	if len(p.moves()) > 0 {
+		return false, Outcome{}
+	}
+
+	b := p.board
+
+	if b.IsInCheck(b.Turn) {
+		return true, Outcome{winFor(b.Opp()), TerminationCheckmate}
+	}
+
+	return true, Outcome{ResultDraw, TerminationStalemate}
+}
+
+// outcome checks if the game is over in the current position, either by the
+// rules of its variant, by the side to move having no moves or by a draw
+// that does not need to be claimed.
+func (h *history) outcome() (bool, Outcome) {
+	if over, o := h.pos.variant.outcome(h); over {
+		return over, o
+	}
+	if over, o := terminal(h.pos); over {
+		return over, o
+	}
+
+	return h.automaticDraw()
+}
@@ -0,0 +1,219 @@
+package chess
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/schafer14/MtM/board"
+	"github.com/schafer14/MtM/common"
+	"github.com/schafer14/MtM/move"
+)
+
+// pieceLetters are the letters used for each piece type in algebraic
+// notation.
+var pieceLetters = [6]string{"", "N", "B", "R", "Q", "K"}
+
+// squareName converts the index of a square into its name like e4.
+func squareName(square uint) string {
+	return fmt.Sprintf("%c%d", 'a'+square%8, square/8+1)
+}
+
+// legalMoves lists all the legal moves in a position.
+func legalMoves(b board.Board) []move.Move32 {
+	var moves []move.Move32
+
+	ml := b.Moves()
+	for {
+		hasNext, m := ml.Next()
+		if !hasNext {
+			return moves
+		}
+		moves = append(moves, m)
+	}
+}
+
+// SAN writes a legal move in standard algebraic notation for the position
+// it is played in.
+func SAN(b board.Board, m move.Move32) string {
+	return writeSAN(newPosition(b, standard{}), m)
+}
+
+// writeSAN writes a legal move of a position in standard algebraic notation.
+func writeSAN(p position, m move.Move32) string {
+	var san string
+
+	if isCastle, kingSide := m.Castle(); isCastle {
+		san = "O-O-O"
+		if kingSide {
+			san = "O-O"
+		}
+	} else if isDrop(m) {
+		san = dropString(m)
+	} else {
+		piece := m.Piece()
+		san = pieceLetters[piece]
+
+		if piece == common.Pawn {
+			if m.IsCap() {
+				san += squareName(m.Src())[:1]
+			}
+		} else {
+			san += disambiguate(p, m)
+		}
+
+		if m.IsCap() {
+			san += "x"
+		}
+		san += squareName(m.Dest())
+
+		if isPromo, promo := m.Promotion(); isPromo {
+			san += "=" + pieceLetters[promo]
+		}
+	}
+
+	// Drops can block a check, so the moves of the board are not enough to
+	// tell mate.
+	p.play(m)
+	if b := p.board; b.IsInCheck(b.Turn) {
+		if len(p.moves()) == 0 {
+			san += "#"
+		} else {
+			san += "+"
+		}
+	}
+
+	return san
+}
+
+// disambiguate finds the part of the source square needed to tell a move
+// apart from moves of other pieces of the same type to the same square. The
+// file is preferred, then the rank and only then the full square.
+func disambiguate(p position, m move.Move32) string {
+	var ambiguous, sameFile, sameRank bool
+
+	for _, other := range p.moves() {
+		if other.Piece() != m.Piece() || other.Dest() != m.Dest() || other.Src() == m.Src() || isDrop(other) {
+			continue
+		}
+		ambiguous = true
+		if other.Src()%8 == m.Src()%8 {
+			sameFile = true
+		}
+		if other.Src()/8 == m.Src()/8 {
+			sameRank = true
+		}
+	}
+
+	src := squareName(m.Src())
+	switch {
+	case !ambiguous:
+		return ""
+	case !sameFile:
+		return src[:1]
+	case !sameRank:
+		return src[1:]
+	default:
+		return src
+	}
+}
+
+// sanPattern matches the parts of a move in standard algebraic notation:
+// the piece, the source file and rank used to disambiguate, the capture
+// marker, the destination square and the promotion piece.
+var sanPattern = regexp.MustCompile(`^([PNBRQK])?([a-h])?([1-8])?(x)?([a-h][1-8])(?:=?([a-zA-Z]))?$`)
+
+var (
+	// ErrInvalidMove is returned for text that is not a move in any of the
+	// supported notations.
+	ErrInvalidMove = errors.New("invalid move format")
+
+	// ErrAmbiguousMove is returned when a move could be made by more than one
+	// piece.
+	ErrAmbiguousMove = errors.New("ambiguous move")
+
+	// ErrIllegalMove is returned for moves that can not be played in the
+	// position.
+	ErrIllegalMove = errors.New("illegal move")
+)
+
+// ParseSAN finds the legal move a move in standard algebraic notation refers
+// to. Check and annotation symbols at the end of the move are ignored.
+func ParseSAN(b board.Board, san string) (move.Move32, error) {
+	return parseSAN(newPosition(b, standard{}), san)
+}
+
+// parseSAN finds the legal move in a position a move in standard algebraic
+// notation refers to.
+func parseSAN(p position, san string) (move.Move32, error) {
+	san = strings.TrimRight(san, "+#!?")
+
+	switch san {
+	case "O-O", "0-0":
+		return findCastle(p, true)
+	case "O-O-O", "0-0-0":
+		return findCastle(p, false)
+	}
+
+	parts := sanPattern.FindStringSubmatch(san)
+	if parts == nil {
+		return 0, ErrInvalidMove
+	}
+
+	piece := common.Pawn
+	if parts[1] != "" {
+		piece = uint(strings.Index("PNBRQK", parts[1]))
+	}
+	dest := squareNum(parts[5])
+	promo, err := promotionLetter(parts[6])
+	if err != nil {
+		return 0, err
+	}
+
+	// Group the moves that fit by the piece making them. Moves of one piece
+	// only differ by their promotion.
+	var sources []uint
+	found := map[uint][]move.Move32{}
+	for _, m := range p.moves() {
+		if m.Piece() != piece || m.Dest() != dest {
+			continue
+		}
+		if isCastle, _ := m.Castle(); isCastle || isDrop(m) {
+			continue
+		}
+
+		src := squareName(m.Src())
+		if parts[2] != "" && src[:1] != parts[2] {
+			continue
+		}
+		if parts[3] != "" && src[1:] != parts[3] {
+			continue
+		}
+
+		if _, ok := found[m.Src()]; !ok {
+			sources = append(sources, m.Src())
+		}
+		found[m.Src()] = append(found[m.Src()], m)
+	}
+
+	switch len(sources) {
+	case 0:
+		return 0, ErrIllegalMove
+	case 1:
+		return matchPromotion(found[sources[0]], promo)
+	default:
+		return 0, ErrAmbiguousMove
+	}
+}
+
+// findCastle finds the legal castling move to either side.
+func findCastle(p position, kingSide bool) (move.Move32, error) {
+	for _, m := range p.moves() {
+		if isCastle, side := m.Castle(); isCastle && side == kingSide {
+			return m, nil
+		}
+	}
+
+	return 0, ErrIllegalMove
+}
@@ -0,0 +1,201 @@
+package chess
+
+import (
+	"context"
+	"encoding/base64"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	// ErrGameNotFound is returned when there is no game with an id.
+	ErrGameNotFound = errors.New("game not found")
+
+	// ErrInvalidCursor is returned for cursors that do not point at a game.
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// GameStore keeps games between requests.
+type GameStore interface {
+	// Create stores a new game.
+	Create(ctx context.Context, g Game) error
+
+	// Get loads the game with an id.
+	Get(ctx context.Context, id string) (Game, error)
+
+	// Update stores a changed game. It fails with ErrConflict if the game
+	// was updated by someone else since it was loaded.
+	Update(ctx context.Context, g Game) error
+
+	// Delete removes the game with an id. Deleting a game that does not
+	// exist is not an error.
+	Delete(ctx context.Context, id string) error
+
+	// List calls fn with every game matching the filter in the order of
+	// their dates. Listing stops at the first error fn returns.
+	List(ctx context.Context, f GameFilter, fn func(Game) error) error
+
+	// MarkSeen records that a player is currently following a game. It does
+	// not change the version of the game and nothing happens if the player
+	// is not playing in the game.
+	MarkSeen(ctx context.Context, id string, playerId string, now time.Time) error
+}
+
+// GameFilter picks the games to list. The zero value lists every game,
+// oldest first.
+type GameFilter struct {
+	// Player only lists games the player plays in.
+	Player string
+
+	// Status only lists games with one of the statuses.
+	Status []int
+
+	// Result only lists games with the result.
+	Result Result
+
+	// Variant only lists games of the variant.
+	Variant string
+
+	// From and To only list games created in the range. From is inclusive
+	// and To exclusive, either can be left out.
+	From, To time.Time
+
+	// Rated only lists rated or casual games.
+	Rated *bool
+
+	// Viewer hides the games the viewer is not allowed to see in lists,
+	// which are the unlisted and private games they do not play in. Nothing
+	// is hidden when there is no viewer.
+	Viewer string
+
+	// Due only lists the games that may have expired at the time: games
+	// where the side to move has run out of time and, when Idle is set,
+	// games nobody has moved in for Idle.
+	Due  time.Time
+	Idle time.Duration
+
+	// Newest lists the newest games first.
+	Newest bool
+
+	// After only lists the games after the game the cursor points at in the
+	// order of the listing.
+	After *Cursor
+
+	// Limit is the most games to list. Zero lists every game.
+	Limit int
+}
+
+// matches checks if a game is picked by the filter. The limit is not taken
+// into account.
+func (f GameFilter) matches(g *game) bool {
+	if f.Player != "" && g.WhiteId != f.Player && g.BlackId != f.Player {
+		return false
+	}
+	if len(f.Status) > 0 && !containsStatus(f.Status, int(g.Status)) {
+		return false
+	}
+	if f.Result != "" && g.Result != f.Result {
+		return false
+	}
+	if f.Variant != "" && g.variant().Name() != f.Variant {
+		return false
+	}
+	if !f.From.IsZero() && g.Date.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !g.Date.Before(f.To) {
+		return false
+	}
+	if f.Rated != nil && g.Rated != *f.Rated {
+		return false
+	}
+	if f.Viewer != "" && !g.listed(f.Viewer) {
+		return false
+	}
+	if !f.Due.IsZero() && !g.due(f.Due, f.Idle) {
+		return false
+	}
+	if f.After != nil && !f.After.before(g, f.Newest) {
+		return false
+	}
+
+	return true
+}
+
+// sortGames puts games in the order of a listing.
+func sortGames(games []*game, newest bool) {
+	sort.Slice(games, func(i, j int) bool {
+		if newest {
+			return CursorFor(games[j]).before(games[i], false)
+		}
+		return CursorFor(games[i]).before(games[j], false)
+	})
+}
+
+// Cursor points at a listed game so that the listing can carry on after it.
+type Cursor struct {
+	Date time.Time
+	Id   primitive.ObjectID
+}
+
+// CursorFor points at a game.
+func CursorFor(gm Game) Cursor {
+	g := gm.(*game)
+	return Cursor{Date: g.Date.Truncate(time.Millisecond), Id: g.Id}
+}
+
+// ParseCursor reads a cursor written by String.
+func ParseCursor(s string) (Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	parts := strings.Split(string(data), ":")
+	if len(parts) != 2 {
+		return Cursor{}, ErrInvalidCursor
+	}
+	ms, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	id, err := primitive.ObjectIDFromHex(parts[1])
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	return Cursor{Date: time.Unix(0, ms*int64(time.Millisecond)).UTC(), Id: id}, nil
+}
+
+// String writes the cursor for clients. Dates are kept to the millisecond
+// like they are in mongo.
+func (c Cursor) String() string {
+	ms := c.Date.UnixNano() / int64(time.Millisecond)
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(ms, 10) + ":" + c.Id.Hex()))
+}
+
+// before checks if the game the cursor points at comes before a game in a
+// listing. Games with the same date are ordered by id.
+func (c Cursor) before(g *game, newest bool) bool {
+	date := g.Date.Truncate(time.Millisecond)
+	if newest {
+		return date.Before(c.Date) || date.Equal(c.Date) && g.Id.Hex() < c.Id.Hex()
+	}
+
+	return date.After(c.Date) || date.Equal(c.Date) && g.Id.Hex() > c.Id.Hex()
+}
+
+func containsStatus(statuses []int, s int) bool {
+	for _, status := range statuses {
+		if status == s {
+			return true
+		}
+	}
+
+	return false
+}
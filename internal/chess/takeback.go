@@ -0,0 +1,88 @@
+package chess
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrNoTakebackProposal is returned when answering a takeback that was
+	// never proposed by the opponent.
+	ErrNoTakebackProposal = errors.New("no takeback has been proposed")
+
+	// ErrNothingToTakeBack is returned when proposing a takeback before the
+	// player has moved.
+	ErrNothingToTakeBack = errors.New("there is no move to take back")
+)
+
+// ProposeTakeback asks the opponent to take back the player's last move.
+// The proposal stands until the opponent answers it or someone moves.
+func (g *game) ProposeTakeback(playerId string, now time.Time) error {
+	color, err := g.activeColor(playerId)
+	if err != nil {
+		return err
+	}
+	if g.takebackPlies(color) == 0 {
+		return ErrNothingToTakeBack
+	}
+
+	g.record(Event{Type: EventTakebackProposed, Time: now, ActorId: playerId, Color: colorName(color)})
+
+	return nil
+}
+
+// AcceptTakeback takes back the last move of the opponent who proposed it.
+// If the player has replied to that move the reply is taken back as well,
+// so that the opponent is to move again.
+func (g *game) AcceptTakeback(playerId string, now time.Time) error {
+	color, err := g.activeColor(playerId)
+	if err != nil {
+		return err
+	}
+	if g.TakebackProposal != colorName(opponent(color)) {
+		return ErrNoTakebackProposal
+	}
+	plies := g.takebackPlies(opponent(color))
+	if plies == 0 {
+		return ErrNothingToTakeBack
+	}
+
+	g.record(Event{Type: EventTakenBack, Time: now, ActorId: playerId, Color: colorName(color), Plies: plies})
+	g.FenString = g.Fen()
+	g.CheckCount = g.Checks()
+	g.PocketState = g.Pockets()
+	g.SANMoves = g.SAN()
+	g.ClockState = g.Clock(now)
+
+	return nil
+}
+
+// DeclineTakeback rejects the opponents takeback proposal.
+func (g *game) DeclineTakeback(playerId string, now time.Time) error {
+	color, err := g.activeColor(playerId)
+	if err != nil {
+		return err
+	}
+	if g.TakebackProposal != colorName(opponent(color)) {
+		return ErrNoTakebackProposal
+	}
+
+	g.record(Event{Type: EventTakebackDeclined, Time: now, ActorId: playerId, Color: colorName(color)})
+
+	return nil
+}
+
+// takebackPlies counts the plies to take back to undo the last move of a
+// color. It is zero when the color has not moved.
+func (g *game) takebackPlies(color uint) int {
+	plies := 1
+	if g.history().pos.board.Turn == color {
+		plies = 2
+	}
+	if len(g.Moves) < plies {
+		return 0
+	}
+
+	return plies
+}
@@ -0,0 +1,85 @@
+package chess
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/schafer14/MtM/common"
+)
+
+func TestTakebackPlies(t *testing.T) {
+	tests := []struct {
+		name  string
+		moves []string
+		color uint
+		plies int
+	}{
+		{"white before any move", nil, common.White, 0},
+		{"black before any move", nil, common.Black, 0},
+		{"white after their move", []string{"e4"}, common.White, 1},
+		{"black before their move", []string{"e4"}, common.Black, 0},
+		{"white after a reply", []string{"e4", "e5"}, common.White, 2},
+		{"black after their move", []string{"e4", "e5"}, common.Black, 1},
+		{"black after a reply", []string{"e4", "e5", "Nf3"}, common.Black, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := startVariant(t, "", "")
+			play(t, g, tt.moves...)
+
+			if got := g.takebackPlies(tt.color); got != tt.plies {
+				t.Errorf("got %d plies, want %d", got, tt.plies)
+			}
+		})
+	}
+}
+
+func TestTakeback(t *testing.T) {
+	tests := []struct {
+		name  string
+		moves []string
+		left  string
+	}{
+		{"taking back the last move", []string{"e4", "e5", "Nf3"}, "e4 e5"},
+		{"taking back a move with its reply", []string{"e4", "e5", "Nf3", "Nc6"}, "e4 e5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now()
+			g := startVariant(t, "", "")
+			play(t, g, tt.moves...)
+
+			if err := g.AcceptTakeback("black", now); err != ErrNoTakebackProposal {
+				t.Errorf("accepting without a proposal: got error %v, want %v", err, ErrNoTakebackProposal)
+			}
+			if err := g.ProposeTakeback("white", now); err != nil {
+				t.Fatalf("proposing takeback: %v", err)
+			}
+			if err := g.AcceptTakeback("white", now); err != ErrNoTakebackProposal {
+				t.Errorf("accepting an own proposal: got error %v, want %v", err, ErrNoTakebackProposal)
+			}
+			if err := g.AcceptTakeback("black", now); err != nil {
+				t.Fatalf("accepting takeback: %v", err)
+			}
+
+			if got := strings.Join(g.SAN(), " "); got != tt.left {
+				t.Errorf("got moves %v, want %v", got, tt.left)
+			}
+			if g.history().pos.board.Turn != common.White || g.TakebackProposal != "" {
+				t.Errorf("got turn %v and proposal %q, want white to move and no proposal", g.history().pos.board.Turn, g.TakebackProposal)
+			}
+		})
+	}
+}
+
+func TestTakebackBeforeMoving(t *testing.T) {
+	g := startVariant(t, "", "")
+	play(t, g, "e4")
+
+	if err := g.ProposeTakeback("black", time.Now()); err != ErrNothingToTakeBack {
+		t.Errorf("proposing before moving: got error %v, want %v", err, ErrNothingToTakeBack)
+	}
+}
@@ -0,0 +1,54 @@
+package chess
+
+import (
+	"github.com/schafer14/MtM/board"
+	"github.com/schafer14/MtM/common"
+)
+
+const TerminationThreeChecks Termination = "three checks"
+
+// Checks are the number of times each side has given check.
+type Checks struct {
+	White int `json:"white"`
+	Black int `json:"black"`
+}
+
+// Checks returns the number of checks each side has given in Three-check
+// games. Checks are not counted in other variants.
+func (g *game) Checks() *Checks {
+	if g.Variant != VariantThreeCheck {
+		return nil
+	}
+
+	h := g.history()
+	return &Checks{White: h.checks[common.White], Black: h.checks[common.Black]}
+}
+
+// threeCheck is standard chess where a player also wins by giving check for
+// the third time.
+type threeCheck struct {
+	standard
+}
+
+func (threeCheck) Name() string {
+	return VariantThreeCheck
+}
+
+func (threeCheck) outcome(h *history) (bool, Outcome) {
+	mover := h.pos.board.Opp()
+	if h.checks[mover] >= 3 {
+		return true, Outcome{winFor(mover), TerminationThreeChecks}
+	}
+
+	return false, Outcome{}
+}
+
+// insufficientMaterial is only the case with bare kings as any other piece
+// can still give check.
+func (threeCheck) insufficientMaterial(b board.Board) bool {
+	return b.Colors[common.White]|b.Colors[common.Black] == b.Pieces[common.King]
+}
+
+func (threeCheck) canWin(b board.Board, color uint) bool {
+	return b.Colors[color]&^b.Pieces[common.King] != 0
+}
@@ -0,0 +1,98 @@
+package chess
+
+import (
+	"github.com/pkg/errors"
+	"github.com/schafer14/MtM/board"
+	"github.com/schafer14/MtM/move"
+)
+
+const (
+	VariantStandard      = "standard"
+	VariantChess960      = "chess960"
+	VariantKingOfTheHill = "kingOfTheHill"
+	VariantThreeCheck    = "threeCheck"
+	VariantRacingKings   = "racingKings"
+	VariantCrazyhouse    = "crazyhouse"
+)
+
+// ErrUnknownVariant is returned when creating a game of a variant that is
+// not supported.
+var ErrUnknownVariant = errors.New("unknown variant")
+
+// Variant is a set of rules played on top of the rules of standard chess.
+// Variants can change where games start, forbid moves that are otherwise
+// legal and end games in ways standard chess does not.
+type Variant interface {
+	// Name identifies the variant in requests and stored games.
+	Name() string
+
+	// start is the FEN of the position a new game starts from. An empty FEN
+	// is the standard starting position.
+	start(opts Options) (string, error)
+
+	// chess960 reports whether castling follows the Chess960 rules.
+	chess960() bool
+
+	// pockets reports whether captured pieces can be dropped back on the
+	// board.
+	pockets() bool
+
+	// allows checks if a move that is legal in standard chess may be played.
+	allows(p position, m move.Move32) bool
+
+	// outcome checks if the rules of the variant end the game in the
+	// position reached after the last move.
+	outcome(h *history) (bool, Outcome)
+
+	// insufficientMaterial checks if neither side could ever win.
+	insufficientMaterial(b board.Board) bool
+
+	// canWin checks if a color has the material to still win the game.
+	canWin(b board.Board, color uint) bool
+}
+
+// variants are the variants games can be played in by name.
+var variants = map[string]Variant{
+	VariantStandard:      standard{},
+	VariantChess960:      fischerRandom{},
+	VariantKingOfTheHill: kingOfTheHill{},
+	VariantThreeCheck:    threeCheck{},
+	VariantRacingKings:   racingKings{},
+	VariantCrazyhouse:    crazyhouse{},
+}
+
+// standard is standard chess. Other variants embed it to keep the rules they
+// do not change.
+type standard struct{}
+
+func (standard) Name() string {
+	return VariantStandard
+}
+
+func (standard) start(opts Options) (string, error) {
+	return opts.Fen, nil
+}
+
+func (standard) chess960() bool {
+	return false
+}
+
+func (standard) pockets() bool {
+	return false
+}
+
+func (standard) allows(p position, m move.Move32) bool {
+	return true
+}
+
+func (standard) outcome(h *history) (bool, Outcome) {
+	return false, Outcome{}
+}
+
+func (standard) insufficientMaterial(b board.Board) bool {
+	return insufficientMaterial(b)
+}
+
+func (standard) canWin(b board.Board, color uint) bool {
+	return !cannotMate(b, color)
+}
@@ -0,0 +1,124 @@
+package chess
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/schafer14/MtM/common"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// startVariant starts a game of a variant from a position between the
+// players white and black.
+func startVariant(t *testing.T, variant string, fen string) *game {
+	t.Helper()
+
+	now := time.Now()
+	g, err := NewGame(primitive.NewObjectID(), now, Player{Id: "white"}, Options{Variant: variant, Fen: fen})
+	if err != nil {
+		t.Fatalf("creating game: %v", err)
+	}
+	if err := g.Join(Player{Id: "black"}, now); err != nil {
+		t.Fatalf("joining game: %v", err)
+	}
+
+	return g.(*game)
+}
+
+// play makes moves for whoever is to move.
+func play(t *testing.T, g *game, moves ...string) {
+	t.Helper()
+
+	for _, m := range moves {
+		player := "white"
+		if g.history().pos.board.Turn == common.Black {
+			player = "black"
+		}
+		if err := g.Move(m, player, time.Now()); err != nil {
+			t.Fatalf("playing %v: %v", m, err)
+		}
+	}
+}
+
+func TestKingOfTheHill(t *testing.T) {
+	tests := []struct {
+		name    string
+		move    string
+		over    bool
+		outcome Outcome
+	}{
+		{"king reaches the hill", "d3d4", true, Outcome{ResultWhiteWins, TerminationKingOfTheHill}},
+		{"king next to the hill", "d3c4", false, Outcome{ResultNone, ""}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := startVariant(t, VariantKingOfTheHill, "4k3/8/8/8/8/3K4/8/8 w - - 0 1")
+			play(t, g, tt.move)
+
+			if g.IsOver() != tt.over || g.Outcome() != tt.outcome {
+				t.Errorf("got over %v with %v, want over %v with %v", g.IsOver(), g.Outcome(), tt.over, tt.outcome)
+			}
+		})
+	}
+}
+
+func TestThreeCheck(t *testing.T) {
+	g := startVariant(t, VariantThreeCheck, "4k3/8/8/8/8/8/8/Q3K3 w - - 0 1")
+
+	play(t, g, "Qa8+", "Ke7", "Qa7+", "Ke6")
+	if got := *g.Checks(); got != (Checks{White: 2, Black: 0}) {
+		t.Errorf("got checks %+v after two checks, want %+v", got, Checks{White: 2})
+	}
+	if g.IsOver() {
+		t.Fatalf("game ended after two checks with %v", g.Outcome())
+	}
+
+	play(t, g, "Qa6+")
+	if got := *g.Checks(); got != (Checks{White: 3, Black: 0}) {
+		t.Errorf("got checks %+v after three checks, want %+v", got, Checks{White: 3})
+	}
+	if want := (Outcome{ResultWhiteWins, TerminationThreeChecks}); g.Outcome() != want {
+		t.Errorf("got %v after the third check, want %v", g.Outcome(), want)
+	}
+}
+
+func TestRacingKingsForbidsChecks(t *testing.T) {
+	g := startVariant(t, VariantRacingKings, "8/8/8/8/8/1k6/8/R6K w - - 0 1")
+
+	if err := g.Move("a1a3", "white", time.Now()); errors.Cause(err) != ErrIllegalMove {
+		t.Errorf("giving check: got error %v, want %v", err, ErrIllegalMove)
+	}
+	if err := g.Move("a1a2", "white", time.Now()); err != nil {
+		t.Errorf("moving without check: %v", err)
+	}
+}
+
+func TestRacingKingsLastMove(t *testing.T) {
+	tests := []struct {
+		name    string
+		fen     string
+		moves   []string
+		outcome Outcome
+	}{
+		{"black can not reach the eighth rank", "8/K7/8/8/8/7k/8/8 w - - 0 1", []string{"Ka8"}, Outcome{ResultWhiteWins, TerminationEighthRank}},
+		{"black reaches the eighth rank", "8/K6k/8/8/8/8/8/8 w - - 0 1", []string{"Ka8", "Kh8"}, Outcome{ResultDraw, TerminationEighthRank}},
+		{"black misses the eighth rank", "8/K6k/8/8/8/8/8/8 w - - 0 1", []string{"Ka8", "Kh6"}, Outcome{ResultWhiteWins, TerminationEighthRank}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := startVariant(t, VariantRacingKings, tt.fen)
+			play(t, g, tt.moves[:len(tt.moves)-1]...)
+			if g.IsOver() {
+				t.Fatalf("game ended early with %v", g.Outcome())
+			}
+
+			play(t, g, tt.moves[len(tt.moves)-1])
+			if g.Outcome() != tt.outcome {
+				t.Errorf("got %v, want %v", g.Outcome(), tt.outcome)
+			}
+		})
+	}
+}
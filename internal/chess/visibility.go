@@ -0,0 +1,32 @@
+package chess
+
+import "github.com/pkg/errors"
+
+// Visibilities of games. Public games are listed for everyone and unlisted
+// games can be seen by anyone who knows their id. Private games can only be
+// seen by their players and by players invited to them.
+const (
+	VisibilityPublic   = "public"
+	VisibilityUnlisted = "unlisted"
+	VisibilityPrivate  = "private"
+)
+
+// ErrUnknownVisibility is returned when creating a game with a visibility
+// that does not exist.
+var ErrUnknownVisibility = errors.New("visibility must be public, unlisted or private")
+
+// Visible checks if a player can see the game without an invite.
+func (g *game) Visible(playerId string) bool {
+	return g.Visibility != VisibilityPrivate || g.isParticipant(playerId)
+}
+
+// Plays checks if a player is playing either side of the game.
+func (g *game) Plays(playerId string) bool {
+	return g.isParticipant(playerId)
+}
+
+// listed checks if the game is listed for a player. Players see their own
+// games whatever their visibility.
+func (g *game) listed(playerId string) bool {
+	return g.Visibility == VisibilityPublic || g.Visibility == "" || g.isParticipant(playerId)
+}
@@ -0,0 +1,75 @@
+// Package invite signs and checks the tokens that invite players to private
+// games. A token is only valid for the game it was made for and until it
+// expires.
+package invite
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrInvalidToken is returned for tokens that were not signed for the
+	// game.
+	ErrInvalidToken = errors.New("invalid invite")
+
+	// ErrExpiredToken is returned for tokens that were signed for the game
+	// but have expired.
+	ErrExpiredToken = errors.New("invite has expired")
+)
+
+// Signer makes and checks invites with a secret key.
+type Signer struct {
+	key      []byte
+	lifetime time.Duration
+}
+
+// New creates a signer of invites that are valid for lifetime.
+func New(key []byte, lifetime time.Duration) *Signer {
+	return &Signer{key: key, lifetime: lifetime}
+}
+
+// Sign makes an invite to a game. It returns the token and when it expires.
+func (s *Signer) Sign(gameId string, now time.Time) (string, time.Time) {
+	expires := now.Add(s.lifetime).Truncate(time.Second)
+	exp := strconv.FormatInt(expires.Unix(), 10)
+
+	return exp + "." + s.mac(gameId, exp), expires
+}
+
+// Verify checks that a token invites to a game at the given time.
+func (s *Signer) Verify(token string, gameId string, now time.Time) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return ErrInvalidToken
+	}
+	exp, mac := parts[0], parts[1]
+
+	if !hmac.Equal([]byte(mac), []byte(s.mac(gameId, exp))) {
+		return ErrInvalidToken
+	}
+
+	unix, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil {
+		return ErrInvalidToken
+	}
+	if !now.Before(time.Unix(unix, 0)) {
+		return ErrExpiredToken
+	}
+
+	return nil
+}
+
+// mac signs a game id and expiry time.
+func (s *Signer) mac(gameId string, exp string) string {
+	h := hmac.New(sha256.New, s.key)
+	h.Write([]byte(gameId + "." + exp))
+
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,240 @@
+// Package lobby keeps the open seeks players post when they look for a
+// game. Another player accepting a seek starts a game between the two.
+package lobby
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"math/rand"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go"
+	"github.com/pkg/errors"
+	"github.com/schafer14/chess-serve/internal/chess"
+)
+
+var (
+	// ErrSeekNotFound is returned for seeks that were accepted, cancelled,
+	// expired or never posted.
+	ErrSeekNotFound = errors.New("seek not found")
+
+	// ErrOwnSeek is returned when a player accepts their own seek.
+	ErrOwnSeek = errors.New("players can not accept their own seek")
+
+	// ErrNotSeeker is returned when a player cancels someone else's seek.
+	ErrNotSeeker = errors.New("only the player who posted a seek can cancel it")
+
+	// ErrRatingOutOfRange is returned when a player's rating is outside of the
+	// rating range of a seek.
+	ErrRatingOutOfRange = errors.New("rating is out of the range of the seek")
+)
+
+// Colors a seeker can ask to play.
+const (
+	ColorWhite  = "white"
+	ColorBlack  = "black"
+	ColorRandom = "random"
+)
+
+// Seek is a player looking for a game with the given settings.
+type Seek struct {
+	Id          string             `json:"id" bson:"_id"`
+	PlayerId    string             `json:"playerId"`
+	Name        string             `json:"name"`
+	Rating      int                `json:"rating"`
+	TimeControl *chess.TimeControl `json:"timeControl"`
+	Variant     string             `json:"variant"`
+	Color       string             `json:"color"`
+	Rated       bool               `json:"rated"`
+	RatingRange *RatingRange       `json:"ratingRange,omitempty"`
+	Posted      time.Time          `json:"posted"`
+}
+
+// RatingRange are the lowest and highest ratings of the players who can
+// accept a seek.
+type RatingRange struct {
+	Min int `json:"min"`
+	Max int `json:"max"`
+}
+
+// Accepted tells the lobby which game a seek started.
+type Accepted struct {
+	SeekId string `json:"seekId"`
+	GameId string `json:"gameId"`
+}
+
+// Lobby holds the open seeks in a store. Everything that changes in the
+// lobby is published on the lobby subjects:
+//
+//	lobby.seek      a seek was posted
+//	lobby.remove    a seek was cancelled or expired
+//	lobby.accepted  a seek was accepted and its game started
+type Lobby struct {
+	seeks    Store
+	games    chess.GameStore
+	nc       *nats.Conn
+	lifetime time.Duration
+}
+
+// New creates a lobby of the seeks in a store. Seeks are removed after they
+// have been open for lifetime.
+func New(seeks Store, games chess.GameStore, nc *nats.Conn, lifetime time.Duration) *Lobby {
+	return &Lobby{
+		seeks:    seeks,
+		games:    games,
+		nc:       nc,
+		lifetime: lifetime,
+	}
+}
+
+// Seeks lists the open seeks, oldest first.
+func (l *Lobby) Seeks(ctx context.Context) ([]Seek, error) {
+	return l.seeks.List(ctx)
+}
+
+// Post opens a seek for a player. The id, player and posting time of the
+// seek are filled in.
+func (l *Lobby) Post(ctx context.Context, s Seek, p chess.Player, now time.Time) (Seek, error) {
+	s.Id = uuid.New().String()
+	s.PlayerId = p.Id
+	s.Name = p.Name
+	s.Rating = p.Rating
+	s.Posted = now
+	if s.Color == "" {
+		s.Color = ColorRandom
+	}
+
+	if err := l.seeks.Add(ctx, s); err != nil {
+		return s, errors.Wrap(err, "posting seek")
+	}
+
+	l.publish("seek", s)
+	return s, nil
+}
+
+// Cancel removes a seek. Only the player who posted it can cancel it.
+func (l *Lobby) Cancel(ctx context.Context, id string, playerId string) error {
+	s, err := l.seeks.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if s.PlayerId != playerId {
+		return ErrNotSeeker
+	}
+	if _, err := l.seeks.Take(ctx, id); err != nil {
+		return err
+	}
+
+	l.publish("remove", map[string]string{"id": id})
+	return nil
+}
+
+// Accept starts the game of a seek between the seeker and the player. The
+// seek is taken out of the store first so that only one player can accept
+// it, even on another server. It is put back if the game can not be
+// created.
+func (l *Lobby) Accept(ctx context.Context, id string, p chess.Player, now time.Time) (chess.Game, error) {
+	s, err := l.seeks.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if s.PlayerId == p.Id {
+		return nil, ErrOwnSeek
+	}
+	if r := s.RatingRange; r != nil && (p.Rating < r.Min || p.Rating > r.Max) {
+		return nil, ErrRatingOutOfRange
+	}
+	if _, err := l.seeks.Take(ctx, id); err != nil {
+		return nil, err
+	}
+
+	game, err := l.start(ctx, s, p, now)
+	if err != nil {
+		if err := l.seeks.Add(ctx, s); err != nil {
+			log.Printf("lobby : putting back seek %v: %v", s.Id, err)
+		}
+		return nil, err
+	}
+
+	l.publish("accepted", Accepted{SeekId: s.Id, GameId: game.ID()})
+	return game, nil
+}
+
+// start creates the game of an accepted seek. The seeker plays the color
+// they asked for and random seeks toss a coin.
+func (l *Lobby) start(ctx context.Context, s Seek, p chess.Player, now time.Time) (chess.Game, error) {
+	seeker := chess.Player{Id: s.PlayerId, Name: s.Name, Rating: s.Rating}
+	white, black := seeker, p
+	if s.Color == ColorBlack || (s.Color == ColorRandom && rand.Intn(2) == 0) {
+		white, black = p, seeker
+	}
+
+	opts := chess.Options{TimeControl: s.TimeControl, Variant: s.Variant, Rated: s.Rated}
+	game, err := chess.StartGame(ctx, l.games, white, black, opts, now)
+
+	return game, errors.Wrap(err, "creating game")
+}
+
+// minExpireInterval is the most often Run looks for expired seeks, which
+// keeps very short lifetimes from spinning.
+const minExpireInterval = time.Second
+
+// Run removes expired seeks until the context is cancelled.
+func (l *Lobby) Run(ctx context.Context) {
+	interval := l.lifetime / 10
+	if interval < minExpireInterval {
+		interval = minExpireInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := l.expire(ctx, now); err != nil {
+				log.Printf("lobby : %v", err)
+			}
+		}
+	}
+}
+
+// expire removes the seeks that have been open for longer than the
+// lifetime of seeks. When several servers share the store only the one that
+// takes an expired seek tells the lobby about it.
+func (l *Lobby) expire(ctx context.Context, now time.Time) error {
+	seeks, err := l.seeks.List(ctx)
+	if err != nil {
+		return errors.Wrap(err, "expiring seeks")
+	}
+
+	for _, s := range seeks {
+		if now.Sub(s.Posted) < l.lifetime {
+			continue
+		}
+		_, err := l.seeks.Take(ctx, s.Id)
+		if err == ErrSeekNotFound {
+			continue
+		}
+		if err != nil {
+			return errors.Wrap(err, "expiring seeks")
+		}
+		l.publish("remove", map[string]string{"id": s.Id})
+	}
+
+	return nil
+}
+
+// publish sends a json encoded event to everyone following the lobby.
+func (l *Lobby) publish(event string, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("lobby : encoding %v event: %v", event, err)
+		return
+	}
+
+	l.nc.Publish("lobby."+event, data)
+}
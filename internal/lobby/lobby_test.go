@@ -0,0 +1,138 @@
+package lobby
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/schafer14/chess-serve/internal/chess"
+)
+
+// failingGames is a game store that can not create games.
+type failingGames struct {
+	chess.GameStore
+}
+
+func (failingGames) Create(ctx context.Context, g chess.Game) error {
+	return errors.New("store is down")
+}
+
+// post opens a seek for the player seeker.
+func post(t *testing.T, l *Lobby, s Seek, now time.Time) Seek {
+	t.Helper()
+
+	s, err := l.Post(context.Background(), s, chess.Player{Id: "seeker", Rating: 1500}, now)
+	if err != nil {
+		t.Fatalf("posting seek: %v", err)
+	}
+
+	return s
+}
+
+func TestAccept(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	l := New(NewMemoryStore(), chess.NewMemoryStore(), nil, time.Minute)
+
+	s := post(t, l, Seek{Color: ColorWhite, RatingRange: &RatingRange{Min: 1400, Max: 1600}}, now)
+
+	tests := []struct {
+		name   string
+		id     string
+		player chess.Player
+		err    error
+	}{
+		{"accepting an unknown seek", "unknown", chess.Player{Id: "other", Rating: 1500}, ErrSeekNotFound},
+		{"accepting an own seek", s.Id, chess.Player{Id: "seeker", Rating: 1500}, ErrOwnSeek},
+		{"rating below the range", s.Id, chess.Player{Id: "other", Rating: 1399}, ErrRatingOutOfRange},
+		{"rating above the range", s.Id, chess.Player{Id: "other", Rating: 1601}, ErrRatingOutOfRange},
+	}
+
+	for _, tt := range tests {
+		if _, err := l.Accept(ctx, tt.id, tt.player, now); err != tt.err {
+			t.Errorf("%v: got error %v, want %v", tt.name, err, tt.err)
+		}
+	}
+
+	game, err := l.Accept(ctx, s.Id, chess.Player{Id: "other", Rating: 1500}, now)
+	if err != nil {
+		t.Fatalf("accepting seek: %v", err)
+	}
+	if _, err := l.Accept(ctx, s.Id, chess.Player{Id: "third", Rating: 1500}, now); err != ErrSeekNotFound {
+		t.Errorf("accepting a taken seek: got error %v, want %v", err, ErrSeekNotFound)
+	}
+
+	var players struct {
+		WhiteId string `json:"whiteId"`
+		BlackId string `json:"blackId"`
+	}
+	data, err := json.Marshal(game)
+	if err != nil {
+		t.Fatalf("encoding game: %v", err)
+	}
+	if err := json.Unmarshal(data, &players); err != nil {
+		t.Fatalf("decoding game: %v", err)
+	}
+	if players.WhiteId != "seeker" || players.BlackId != "other" {
+		t.Errorf("got %v against %v, want seeker playing white", players.WhiteId, players.BlackId)
+	}
+}
+
+func TestAcceptPutsBackSeek(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	seeks := NewMemoryStore()
+	l := New(seeks, failingGames{chess.NewMemoryStore()}, nil, time.Minute)
+
+	s := post(t, l, Seek{}, now)
+	if _, err := l.Accept(ctx, s.Id, chess.Player{Id: "other"}, now); err == nil {
+		t.Fatal("accepting seek without a game store: got no error")
+	}
+
+	back, err := seeks.Get(ctx, s.Id)
+	if err != nil {
+		t.Fatalf("seek was not put back: %v", err)
+	}
+	if back != s {
+		t.Errorf("put back %+v, want %+v", back, s)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	ctx := context.Background()
+	l := New(NewMemoryStore(), chess.NewMemoryStore(), nil, time.Minute)
+	s := post(t, l, Seek{}, time.Now())
+
+	if err := l.Cancel(ctx, s.Id, "other"); err != ErrNotSeeker {
+		t.Errorf("cancelling someone else's seek: got error %v, want %v", err, ErrNotSeeker)
+	}
+	if err := l.Cancel(ctx, s.Id, "seeker"); err != nil {
+		t.Errorf("cancelling seek: %v", err)
+	}
+	if err := l.Cancel(ctx, s.Id, "seeker"); err != ErrSeekNotFound {
+		t.Errorf("cancelling a cancelled seek: got error %v, want %v", err, ErrSeekNotFound)
+	}
+}
+
+func TestExpire(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	l := New(NewMemoryStore(), chess.NewMemoryStore(), nil, time.Minute)
+
+	old := post(t, l, Seek{}, now.Add(-time.Minute))
+	fresh := post(t, l, Seek{}, now.Add(-time.Minute+time.Second))
+
+	if err := l.expire(ctx, now); err != nil {
+		t.Fatalf("expiring seeks: %v", err)
+	}
+
+	seeks, err := l.Seeks(ctx)
+	if err != nil {
+		t.Fatalf("listing seeks: %v", err)
+	}
+	if len(seeks) != 1 || seeks[0].Id != fresh.Id {
+		t.Errorf("got seeks %+v after %v expired, want only %v", seeks, old.Id, fresh.Id)
+	}
+}
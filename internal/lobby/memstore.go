@@ -0,0 +1,73 @@
+package lobby
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// MemoryStore keeps seeks in memory. Seeks are lost when the process stops
+// and can only be seen by the server that keeps them.
+type MemoryStore struct {
+	mu    sync.Mutex
+	seeks map[string]Seek
+}
+
+var _ Store = &MemoryStore{}
+
+// NewMemoryStore creates an empty store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{seeks: map[string]Seek{}}
+}
+
+// Add stores a new seek.
+func (s *MemoryStore) Add(ctx context.Context, seek Seek) error {
+	s.mu.Lock()
+	s.seeks[seek.Id] = seek
+	s.mu.Unlock()
+
+	return nil
+}
+
+// Get loads the seek with an id.
+func (s *MemoryStore) Get(ctx context.Context, id string) (Seek, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seek, ok := s.seeks[id]
+	if !ok {
+		return seek, ErrSeekNotFound
+	}
+
+	return seek, nil
+}
+
+// List loads every seek, oldest first.
+func (s *MemoryStore) List(ctx context.Context) ([]Seek, error) {
+	s.mu.Lock()
+	seeks := make([]Seek, 0, len(s.seeks))
+	for _, seek := range s.seeks {
+		seeks = append(seeks, seek)
+	}
+	s.mu.Unlock()
+
+	sort.Slice(seeks, func(i, j int) bool {
+		return seeks[i].Posted.Before(seeks[j].Posted)
+	})
+
+	return seeks, nil
+}
+
+// Take removes the seek with an id and returns it.
+func (s *MemoryStore) Take(ctx context.Context, id string) (Seek, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seek, ok := s.seeks[id]
+	if !ok {
+		return seek, ErrSeekNotFound
+	}
+	delete(s.seeks, id)
+
+	return seek, nil
+}
@@ -0,0 +1,76 @@
+package lobby
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoStore keeps seeks in a mongo collection.
+type MongoStore struct {
+	coll *mongo.Collection
+}
+
+var _ Store = &MongoStore{}
+
+// NewMongoStore creates a store for the seeks in a collection.
+func NewMongoStore(coll *mongo.Collection) *MongoStore {
+	return &MongoStore{coll: coll}
+}
+
+// Add stores a new seek.
+func (s *MongoStore) Add(ctx context.Context, seek Seek) error {
+	if _, err := s.coll.InsertOne(ctx, seek); err != nil {
+		return errors.Wrap(err, "inserting seek")
+	}
+
+	return nil
+}
+
+// Get loads the seek with an id.
+func (s *MongoStore) Get(ctx context.Context, id string) (Seek, error) {
+	var seek Seek
+	err := s.coll.FindOne(ctx, byId(id)).Decode(&seek)
+	if err == mongo.ErrNoDocuments {
+		return seek, ErrSeekNotFound
+	}
+
+	return seek, errors.Wrap(err, "retrieving seek")
+}
+
+// List loads every seek, oldest first.
+func (s *MongoStore) List(ctx context.Context) ([]Seek, error) {
+	opts := options.Find().SetSort(bson.D{primitive.E{Key: "posted", Value: 1}})
+	cur, err := s.coll.Find(ctx, bson.D{}, opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "finding seeks")
+	}
+
+	seeks := []Seek{}
+	if err := cur.All(ctx, &seeks); err != nil {
+		return nil, errors.Wrap(err, "decoding seeks")
+	}
+
+	return seeks, nil
+}
+
+// Take removes the seek with an id and returns it. Mongo deletes the seek
+// and returns it in one step so only one caller gets it.
+func (s *MongoStore) Take(ctx context.Context, id string) (Seek, error) {
+	var seek Seek
+	err := s.coll.FindOneAndDelete(ctx, byId(id)).Decode(&seek)
+	if err == mongo.ErrNoDocuments {
+		return seek, ErrSeekNotFound
+	}
+
+	return seek, errors.Wrap(err, "taking seek")
+}
+
+// byId matches the seek with an id.
+func byId(id string) bson.D {
+	return bson.D{primitive.E{Key: "_id", Value: id}}
+}
@@ -0,0 +1,23 @@
+package lobby
+
+import (
+	"context"
+)
+
+// Store keeps the open seeks. A store other than the memory store can be
+// shared by several servers, which then all see the same lobby.
+type Store interface {
+	// Add stores a new seek.
+	Add(ctx context.Context, s Seek) error
+
+	// Get loads the seek with an id. It fails with ErrSeekNotFound if
+	// there is none.
+	Get(ctx context.Context, id string) (Seek, error)
+
+	// List loads every seek, oldest first.
+	List(ctx context.Context) ([]Seek, error)
+
+	// Take removes the seek with an id and returns it. Only one caller can
+	// take a seek, it fails with ErrSeekNotFound for everyone else.
+	Take(ctx context.Context, id string) (Seek, error)
+}
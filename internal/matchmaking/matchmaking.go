@@ -0,0 +1,276 @@
+// Package matchmaking pairs up players who want to play a game now. Players
+// wait in a pool for the game they want and are paired with the closest
+// rated player in the same pool.
+package matchmaking
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/pkg/errors"
+	"github.com/schafer14/chess-serve/internal/chess"
+)
+
+// ErrNotQueued is returned when a player who is not waiting leaves the queue.
+var ErrNotQueued = errors.New("player is not in the queue")
+
+const (
+	// startRange is how far apart the ratings of two players can be when
+	// they start waiting.
+	startRange = 50
+
+	// rangeGrowth is how much further apart the ratings can be for every
+	// second a player waits.
+	rangeGrowth = 10
+
+	// maxRange is the furthest apart the ratings of two players can be.
+	maxRange = 500
+
+	// rematchDelay is how long two players who were just paired are kept
+	// from being paired again.
+	rematchDelay = time.Minute
+)
+
+// Pool are the settings of the game the players in a pool are waiting for.
+// Untimed games have a zero time control.
+type Pool struct {
+	TimeControl chess.TimeControl `json:"timeControl"`
+	Variant     string            `json:"variant"`
+	Rated       bool              `json:"rated"`
+}
+
+// Ticket is a player waiting in a pool.
+type Ticket struct {
+	Player chess.Player `json:"player"`
+	Pool   Pool         `json:"pool"`
+	Joined time.Time    `json:"joined"`
+}
+
+// window is how far apart the rating of the player and their opponent can
+// be after waiting until now.
+func (t Ticket) window(now time.Time) int {
+	r := startRange + int(now.Sub(t.Joined)/time.Second)*rangeGrowth
+	if r > maxRange {
+		return maxRange
+	}
+
+	return r
+}
+
+// Match tells a player about the game they were paired into.
+type Match struct {
+	GameId   string       `json:"gameId"`
+	Color    string       `json:"color"`
+	Opponent chess.Player `json:"opponent"`
+}
+
+// Queue holds the players waiting for a game in a store. Players are told
+// about their games on their own subject, see Subject.
+type Queue struct {
+	store    Store
+	games    chess.GameStore
+	nc       *nats.Conn
+	interval time.Duration
+}
+
+// New creates a queue of the players in a store that pairs players every
+// interval.
+func New(store Store, games chess.GameStore, nc *nats.Conn, interval time.Duration) *Queue {
+	return &Queue{
+		store:    store,
+		games:    games,
+		nc:       nc,
+		interval: interval,
+	}
+}
+
+// Subject is where a player is told about the games they are paired into.
+// Player ids are encoded because they can be emails, which have dots.
+func Subject(playerId string) string {
+	return "matchmaking." + hex.EncodeToString([]byte(playerId))
+}
+
+// Join puts a player in a pool. A player only waits in one pool at a time,
+// joining again moves them to the new pool.
+func (q *Queue) Join(ctx context.Context, p chess.Player, pool Pool, now time.Time) (Ticket, error) {
+	if pool.Variant == "" {
+		pool.Variant = chess.VariantStandard
+	}
+	t := Ticket{Player: p, Pool: pool, Joined: now}
+
+	if err := q.store.Put(ctx, t); err != nil {
+		return t, errors.Wrap(err, "joining queue")
+	}
+
+	return t, nil
+}
+
+// Leave takes a player out of the queue.
+func (q *Queue) Leave(ctx context.Context, playerId string) error {
+	_, err := q.store.Take(ctx, playerId)
+	return err
+}
+
+// Run pairs players until the context is cancelled.
+func (q *Queue) Run(ctx context.Context) {
+	ticker := time.NewTicker(q.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := q.pair(ctx, now); err != nil {
+				log.Printf("matchmaking : %v", err)
+			}
+		}
+	}
+}
+
+// pair starts a game for every pair of players that can play each other.
+// The players who have waited longest are paired first. When several
+// servers share the queue they may pair the same players, only the server
+// that takes both tickets starts their game.
+func (q *Queue) pair(ctx context.Context, now time.Time) error {
+	tickets, err := q.store.List(ctx)
+	if err != nil {
+		return errors.Wrap(err, "listing tickets")
+	}
+	last, err := q.store.LastOpponents(ctx, now.Add(-rematchDelay))
+	if err != nil {
+		return errors.Wrap(err, "listing pairings")
+	}
+
+	pools := map[Pool][]Ticket{}
+	for _, t := range tickets {
+		pools[t.Pool] = append(pools[t.Pool], t)
+	}
+
+	var pairs [][2]Ticket
+	for _, tickets := range pools {
+		sort.Slice(tickets, func(i, j int) bool {
+			return tickets[i].Joined.Before(tickets[j].Joined)
+		})
+
+		paired := map[int]bool{}
+		for i, a := range tickets {
+			if paired[i] {
+				continue
+			}
+
+			best := -1
+			for j := i + 1; j < len(tickets); j++ {
+				b := tickets[j]
+				diff := abs(a.Player.Rating - b.Player.Rating)
+				if paired[j] || diff > a.window(now) || diff > b.window(now) || justPlayed(last, a, b) {
+					continue
+				}
+				if best < 0 || diff < abs(a.Player.Rating-tickets[best].Player.Rating) {
+					best = j
+				}
+			}
+			if best < 0 {
+				continue
+			}
+
+			paired[i], paired[best] = true, true
+			pairs = append(pairs, [2]Ticket{a, tickets[best]})
+		}
+	}
+
+	for _, pair := range pairs {
+		if err := q.claim(ctx, pair[0], pair[1]); err == ErrNotQueued {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if err := q.start(ctx, pair[0], pair[1], now); err != nil {
+			log.Printf("matchmaking : %v", err)
+		}
+	}
+
+	return nil
+}
+
+// claim takes the tickets of two paired players out of the queue. It fails
+// with ErrNotQueued if either player has left or was paired by someone
+// else, the other player stays in the queue then.
+func (q *Queue) claim(ctx context.Context, a, b Ticket) error {
+	if _, err := q.store.Take(ctx, a.Player.Id); err != nil {
+		return err
+	}
+
+	if _, err := q.store.Take(ctx, b.Player.Id); err != nil {
+		if err := q.store.Restore(ctx, a); err != nil {
+			log.Printf("matchmaking : putting back %v: %v", a.Player.Id, err)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// justPlayed checks if two players were paired with each other too
+// recently to be paired again.
+func justPlayed(last map[string]string, a, b Ticket) bool {
+	return last[a.Player.Id] == b.Player.Id || last[b.Player.Id] == a.Player.Id
+}
+
+// start creates the game of two paired players with random colors and tells
+// both of them about it. The players are put back in the queue if the game
+// can not be created.
+func (q *Queue) start(ctx context.Context, a, b Ticket, now time.Time) error {
+	white, black := a.Player, b.Player
+	if rand.Intn(2) == 0 {
+		white, black = black, white
+	}
+
+	opts := chess.Options{Variant: a.Pool.Variant, Rated: a.Pool.Rated}
+	if tc := a.Pool.TimeControl; tc != (chess.TimeControl{}) {
+		opts.TimeControl = &tc
+	}
+
+	game, err := chess.StartGame(ctx, q.games, white, black, opts, now)
+	if err != nil {
+		for _, t := range []Ticket{a, b} {
+			if err := q.store.Restore(ctx, t); err != nil {
+				log.Printf("matchmaking : putting back %v: %v", t.Player.Id, err)
+			}
+		}
+		return errors.Wrap(err, "creating game")
+	}
+
+	if err := q.store.Paired(ctx, white.Id, black.Id, now); err != nil {
+		log.Printf("matchmaking : %v", err)
+	}
+
+	q.publish(white.Id, Match{GameId: game.ID(), Color: "white", Opponent: black})
+	q.publish(black.Id, Match{GameId: game.ID(), Color: "black", Opponent: white})
+	return nil
+}
+
+// publish sends a json encoded match to a player.
+func (q *Queue) publish(playerId string, m Match) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		log.Printf("matchmaking : encoding match: %v", err)
+		return
+	}
+
+	q.nc.Publish(Subject(playerId)+".matched", data)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+
+	return n
+}
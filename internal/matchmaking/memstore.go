@@ -0,0 +1,106 @@
+package matchmaking
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps the queue in memory. Players waiting are lost when the
+// process stops and can only be paired by the server that keeps them.
+type MemoryStore struct {
+	mu      sync.Mutex
+	tickets map[string]Ticket
+	last    map[string]pairing
+}
+
+// pairing is the last opponent a player was paired with.
+type pairing struct {
+	opponent string
+	at       time.Time
+}
+
+var _ Store = &MemoryStore{}
+
+// NewMemoryStore creates an empty store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		tickets: map[string]Ticket{},
+		last:    map[string]pairing{},
+	}
+}
+
+// Put stores the ticket of a player in place of the ticket they had.
+func (s *MemoryStore) Put(ctx context.Context, t Ticket) error {
+	s.mu.Lock()
+	s.tickets[t.Player.Id] = t
+	s.mu.Unlock()
+
+	return nil
+}
+
+// Restore stores the ticket of a player unless they have one.
+func (s *MemoryStore) Restore(ctx context.Context, t Ticket) error {
+	s.mu.Lock()
+	if _, ok := s.tickets[t.Player.Id]; !ok {
+		s.tickets[t.Player.Id] = t
+	}
+	s.mu.Unlock()
+
+	return nil
+}
+
+// List loads every ticket.
+func (s *MemoryStore) List(ctx context.Context) ([]Ticket, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tickets := make([]Ticket, 0, len(s.tickets))
+	for _, t := range s.tickets {
+		tickets = append(tickets, t)
+	}
+
+	return tickets, nil
+}
+
+// Take removes the ticket of a player and returns it.
+func (s *MemoryStore) Take(ctx context.Context, playerId string) (Ticket, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tickets[playerId]
+	if !ok {
+		return t, ErrNotQueued
+	}
+	delete(s.tickets, playerId)
+
+	return t, nil
+}
+
+// Paired remembers that two players were paired at a time.
+func (s *MemoryStore) Paired(ctx context.Context, a, b string, at time.Time) error {
+	s.mu.Lock()
+	s.last[a] = pairing{opponent: b, at: at}
+	s.last[b] = pairing{opponent: a, at: at}
+	s.mu.Unlock()
+
+	return nil
+}
+
+// LastOpponents are the last opponents of the players paired since a time.
+// Older pairings are forgotten.
+func (s *MemoryStore) LastOpponents(ctx context.Context, since time.Time) (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	opponents := map[string]string{}
+	for id, last := range s.last {
+		if last.at.Before(since) {
+			delete(s.last, id)
+			continue
+		}
+		opponents[id] = last.opponent
+	}
+
+	return opponents, nil
+}
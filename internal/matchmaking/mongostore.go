@@ -0,0 +1,142 @@
+package matchmaking
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoStore keeps tickets and pairings in mongo collections. Both are
+// stored under the id of their player.
+type MongoStore struct {
+	tickets  *mongo.Collection
+	pairings *mongo.Collection
+}
+
+var _ Store = &MongoStore{}
+
+// NewMongoStore creates a store for the tickets and pairings in two
+// collections.
+func NewMongoStore(tickets *mongo.Collection, pairings *mongo.Collection) *MongoStore {
+	return &MongoStore{tickets: tickets, pairings: pairings}
+}
+
+// ticketDoc is a ticket stored under the id of its player.
+type ticketDoc struct {
+	Id     string `bson:"_id"`
+	Ticket `bson:",inline"`
+}
+
+// pairingDoc is the last opponent of a player.
+type pairingDoc struct {
+	Id       string    `bson:"_id"`
+	Opponent string    `bson:"opponent"`
+	At       time.Time `bson:"at"`
+}
+
+// Put stores the ticket of a player in place of the ticket they had.
+func (s *MongoStore) Put(ctx context.Context, t Ticket) error {
+	opts := options.Replace().SetUpsert(true)
+	if _, err := s.tickets.ReplaceOne(ctx, byId(t.Player.Id), ticketDoc{t.Player.Id, t}, opts); err != nil {
+		return errors.Wrap(err, "saving ticket")
+	}
+
+	return nil
+}
+
+// Restore stores the ticket of a player unless they have one.
+func (s *MongoStore) Restore(ctx context.Context, t Ticket) error {
+	update := bson.D{primitive.E{Key: "$setOnInsert", Value: t}}
+	opts := options.Update().SetUpsert(true)
+	if _, err := s.tickets.UpdateOne(ctx, byId(t.Player.Id), update, opts); err != nil {
+		return errors.Wrap(err, "restoring ticket")
+	}
+
+	return nil
+}
+
+// List loads every ticket.
+func (s *MongoStore) List(ctx context.Context) ([]Ticket, error) {
+	cur, err := s.tickets.Find(ctx, bson.D{})
+	if err != nil {
+		return nil, errors.Wrap(err, "finding tickets")
+	}
+
+	var docs []ticketDoc
+	if err := cur.All(ctx, &docs); err != nil {
+		return nil, errors.Wrap(err, "decoding tickets")
+	}
+
+	tickets := make([]Ticket, len(docs))
+	for i, doc := range docs {
+		tickets[i] = doc.Ticket
+	}
+
+	return tickets, nil
+}
+
+// Take removes the ticket of a player and returns it. Mongo deletes the
+// ticket and returns it in one step so only one caller gets it.
+func (s *MongoStore) Take(ctx context.Context, playerId string) (Ticket, error) {
+	var doc ticketDoc
+	err := s.tickets.FindOneAndDelete(ctx, byId(playerId)).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return doc.Ticket, ErrNotQueued
+	}
+
+	return doc.Ticket, errors.Wrap(err, "taking ticket")
+}
+
+// Paired remembers that two players were paired at a time.
+func (s *MongoStore) Paired(ctx context.Context, a, b string, at time.Time) error {
+	opts := options.Replace().SetUpsert(true)
+	for _, p := range []pairingDoc{{a, b, at}, {b, a, at}} {
+		if _, err := s.pairings.ReplaceOne(ctx, byId(p.Id), p, opts); err != nil {
+			return errors.Wrap(err, "saving pairing")
+		}
+	}
+
+	return nil
+}
+
+// LastOpponents are the last opponents of the players paired since a time.
+func (s *MongoStore) LastOpponents(ctx context.Context, since time.Time) (map[string]string, error) {
+	filter := bson.D{primitive.E{Key: "at", Value: bson.D{primitive.E{Key: "$gte", Value: since}}}}
+	cur, err := s.pairings.Find(ctx, filter)
+	if err != nil {
+		return nil, errors.Wrap(err, "finding pairings")
+	}
+
+	var docs []pairingDoc
+	if err := cur.All(ctx, &docs); err != nil {
+		return nil, errors.Wrap(err, "decoding pairings")
+	}
+
+	opponents := map[string]string{}
+	for _, doc := range docs {
+		opponents[doc.Id] = doc.Opponent
+	}
+
+	return opponents, nil
+}
+
+// EnsureIndexes creates the index that removes pairings once they are too
+// old to keep players apart.
+func (s *MongoStore) EnsureIndexes(ctx context.Context) error {
+	_, err := s.pairings.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{primitive.E{Key: "at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(int32(rematchDelay / time.Second)),
+	})
+
+	return errors.Wrap(err, "creating pairing indexes")
+}
+
+// byId matches the document of a player.
+func byId(playerId string) bson.D {
+	return bson.D{primitive.E{Key: "_id", Value: playerId}}
+}
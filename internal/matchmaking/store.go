@@ -0,0 +1,31 @@
+package matchmaking
+
+import (
+	"context"
+	"time"
+)
+
+// Store keeps the tickets of the players waiting in the queue and who they
+// were last paired with. A store other than the memory store can be shared
+// by several servers, which then all pair players from the same queue.
+type Store interface {
+	// Put stores the ticket of a player in place of the ticket they had.
+	Put(ctx context.Context, t Ticket) error
+
+	// Restore stores the ticket of a player unless they have one.
+	Restore(ctx context.Context, t Ticket) error
+
+	// List loads every ticket.
+	List(ctx context.Context) ([]Ticket, error)
+
+	// Take removes the ticket of a player and returns it. Only one caller
+	// can take a ticket, it fails with ErrNotQueued for everyone else.
+	Take(ctx context.Context, playerId string) (Ticket, error)
+
+	// Paired remembers that two players were paired at a time.
+	Paired(ctx context.Context, a, b string, at time.Time) error
+
+	// LastOpponents are the players each player was last paired with for
+	// the players paired since a time.
+	LastOpponents(ctx context.Context, since time.Time) (map[string]string, error)
+}
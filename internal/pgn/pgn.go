@@ -0,0 +1,359 @@
+// Package pgn reads games written in portable game notation.
+//
+// Parsing handles tag pairs, movetext with move numbers, comments, numeric
+// annotation glyphs and recursive variations. Playing a parsed game checks
+// every move against the rules of chess.
+package pgn
+
+import (
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Game is a single game read from a PGN file.
+type Game struct {
+	Tags     map[string]string
+	Comments []string
+	Moves    []Move
+	Result   string
+}
+
+// Move is a move of the movetext with everything written about it.
+type Move struct {
+	SAN        string
+	NAGs       []int
+	Comments   []string
+	Variations [][]Move
+}
+
+// suffixNAGs are the numeric annotation glyphs written as move suffixes.
+var suffixNAGs = map[string]int{
+	"!":  1,
+	"?":  2,
+	"!!": 3,
+	"??": 4,
+	"!?": 5,
+	"?!": 6,
+}
+
+// results are the game termination markers that end the movetext.
+var results = map[string]bool{
+	"1-0":     true,
+	"0-1":     true,
+	"1/2-1/2": true,
+	"*":       true,
+}
+
+// Parse reads every game in a PGN file.
+func Parse(r io.Reader) ([]Game, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading pgn")
+	}
+
+	p := parser{s: newScanner(string(data))}
+	p.next()
+
+	var games []Game
+	for p.tok.kind != tokenEOF {
+		g, err := p.game()
+		if err != nil {
+			return games, errors.Wrapf(err, "parsing game %d", len(games)+1)
+		}
+		games = append(games, g)
+	}
+
+	return games, nil
+}
+
+// parser builds games from the tokens of a PGN file.
+type parser struct {
+	s   *scanner
+	tok token
+}
+
+func (p *parser) next() {
+	p.tok = p.s.scan()
+}
+
+// game reads the tag pairs and movetext of a single game.
+func (p *parser) game() (Game, error) {
+	g := Game{Tags: map[string]string{}, Result: "*"}
+
+	for p.tok.kind == tokenTagOpen {
+		p.next()
+		if p.tok.kind != tokenSymbol {
+			return g, errors.Errorf("line %d: expected tag name", p.tok.line)
+		}
+		name := p.tok.text
+
+		p.next()
+		if p.tok.kind != tokenString {
+			return g, errors.Errorf("line %d: expected value for tag %s", p.tok.line, name)
+		}
+		g.Tags[name] = p.tok.text
+
+		p.next()
+		if p.tok.kind != tokenTagClose {
+			return g, errors.Errorf("line %d: expected ] after tag %s", p.tok.line, name)
+		}
+		p.next()
+	}
+
+	for p.tok.kind == tokenComment {
+		g.Comments = append(g.Comments, p.tok.text)
+		p.next()
+	}
+
+	moves, err := p.line(0)
+	if err != nil {
+		return g, err
+	}
+	g.Moves = moves
+
+	if p.tok.kind == tokenResult {
+		g.Result = p.tok.text
+		p.next()
+	} else if result, ok := g.Tags["Result"]; ok && results[result] {
+		g.Result = result
+	}
+
+	return g, nil
+}
+
+// line reads a sequence of moves. Variations are read recursively and end
+// at their closing parenthesis, the main line ends at the game result or the
+// tags of the next game.
+func (p *parser) line(depth int) ([]Move, error) {
+	var moves []Move
+
+	for {
+		switch p.tok.kind {
+		case tokenEOF, tokenResult, tokenTagOpen:
+			if depth > 0 {
+				return moves, errors.Errorf("line %d: unterminated variation", p.tok.line)
+			}
+			return moves, nil
+
+		case tokenVariationClose:
+			if depth == 0 {
+				return moves, errors.Errorf("line %d: unexpected )", p.tok.line)
+			}
+			return moves, nil
+
+		case tokenVariationOpen:
+			if len(moves) == 0 {
+				return moves, errors.Errorf("line %d: variation before any move", p.tok.line)
+			}
+			p.next()
+			variation, err := p.line(depth + 1)
+			if err != nil {
+				return moves, err
+			}
+			last := &moves[len(moves)-1]
+			last.Variations = append(last.Variations, variation)
+
+		case tokenMoveNumber:
+
+		case tokenSymbol:
+			moves = append(moves, Move{SAN: p.tok.text})
+
+		case tokenSuffix:
+			if len(moves) == 0 {
+				return moves, errors.Errorf("line %d: annotation before any move", p.tok.line)
+			}
+			last := &moves[len(moves)-1]
+			last.NAGs = append(last.NAGs, suffixNAGs[p.tok.text])
+
+		case tokenNAG:
+			if len(moves) == 0 {
+				return moves, errors.Errorf("line %d: annotation before any move", p.tok.line)
+			}
+			nag, err := strconv.Atoi(p.tok.text)
+			if err != nil {
+				return moves, errors.Errorf("line %d: invalid annotation $%s", p.tok.line, p.tok.text)
+			}
+			last := &moves[len(moves)-1]
+			last.NAGs = append(last.NAGs, nag)
+
+		case tokenComment:
+			if len(moves) > 0 {
+				last := &moves[len(moves)-1]
+				last.Comments = append(last.Comments, p.tok.text)
+			}
+
+		default:
+			return moves, errors.Errorf("line %d: unexpected %q", p.tok.line, p.tok.text)
+		}
+
+		p.next()
+	}
+}
+
+// tokenKind is the type of a token in a PGN file.
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenTagOpen
+	tokenTagClose
+	tokenString
+	tokenSymbol
+	tokenMoveNumber
+	tokenSuffix
+	tokenNAG
+	tokenComment
+	tokenVariationOpen
+	tokenVariationClose
+	tokenResult
+	tokenInvalid
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	line int
+}
+
+// scanner splits a PGN file into tokens.
+type scanner struct {
+	src  string
+	pos  int
+	line int
+}
+
+func newScanner(src string) *scanner {
+	return &scanner{src: src, line: 1}
+}
+
+// isSymbolChar checks if a character can appear in a symbol like a move or
+// tag name.
+func isSymbolChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.IndexByte("_+#=:-/", c) >= 0
+}
+
+// scan reads the next token.
+func (s *scanner) scan() token {
+	s.skip()
+	if s.pos >= len(s.src) {
+		return token{kind: tokenEOF, line: s.line}
+	}
+
+	start := s.pos
+	line := s.line
+	c := s.src[s.pos]
+	s.pos++
+
+	switch {
+	case c == '[':
+		return token{tokenTagOpen, "[", line}
+	case c == ']':
+		return token{tokenTagClose, "]", line}
+	case c == '(':
+		return token{tokenVariationOpen, "(", line}
+	case c == ')':
+		return token{tokenVariationClose, ")", line}
+	case c == '*':
+		return token{tokenResult, "*", line}
+
+	case c == '"':
+		var b strings.Builder
+		for s.pos < len(s.src) && s.src[s.pos] != '"' {
+			if s.src[s.pos] == '\\' && s.pos+1 < len(s.src) {
+				s.pos++
+			}
+			if s.src[s.pos] == '\n' {
+				s.line++
+			}
+			b.WriteByte(s.src[s.pos])
+			s.pos++
+		}
+		s.pos++
+		return token{tokenString, b.String(), line}
+
+	case c == '{':
+		end := strings.IndexByte(s.src[s.pos:], '}')
+		if end < 0 {
+			end = len(s.src) - s.pos
+		}
+		text := s.src[s.pos : s.pos+end]
+		s.line += strings.Count(text, "\n")
+		s.pos += end + 1
+		return token{tokenComment, strings.TrimSpace(text), line}
+
+	case c == ';':
+		end := strings.IndexByte(s.src[s.pos:], '\n')
+		if end < 0 {
+			end = len(s.src) - s.pos
+		}
+		text := s.src[s.pos : s.pos+end]
+		s.pos += end
+		return token{tokenComment, strings.TrimSpace(text), line}
+
+	case c == '$':
+		for s.pos < len(s.src) && s.src[s.pos] >= '0' && s.src[s.pos] <= '9' {
+			s.pos++
+		}
+		return token{tokenNAG, s.src[start+1 : s.pos], line}
+
+	case c == '!' || c == '?':
+		for s.pos < len(s.src) && (s.src[s.pos] == '!' || s.src[s.pos] == '?') {
+			s.pos++
+		}
+		return token{tokenSuffix, s.src[start:s.pos], line}
+
+	case isSymbolChar(c):
+		for s.pos < len(s.src) && isSymbolChar(s.src[s.pos]) {
+			s.pos++
+		}
+		text := s.src[start:s.pos]
+
+		if results[text] {
+			return token{tokenResult, text, line}
+		}
+
+		// Move numbers are followed by one or more periods.
+		if strings.Trim(text, "0123456789") == "" {
+			for s.pos < len(s.src) && s.src[s.pos] == '.' {
+				s.pos++
+			}
+			return token{tokenMoveNumber, text, line}
+		}
+
+		return token{tokenSymbol, text, line}
+
+	case c == '.':
+		for s.pos < len(s.src) && s.src[s.pos] == '.' {
+			s.pos++
+		}
+		return token{tokenMoveNumber, s.src[start:s.pos], line}
+	}
+
+	return token{tokenInvalid, string(c), line}
+}
+
+// skip moves past white space and escaped lines starting with %.
+func (s *scanner) skip() {
+	for s.pos < len(s.src) {
+		c := s.src[s.pos]
+		switch {
+		case c == '%' && (s.pos == 0 || s.src[s.pos-1] == '\n'):
+			end := strings.IndexByte(s.src[s.pos:], '\n')
+			if end < 0 {
+				end = len(s.src) - s.pos
+			}
+			s.pos += end
+		case c == '\n':
+			s.line++
+			s.pos++
+		case c == ' ' || c == '\t' || c == '\r':
+			s.pos++
+		default:
+			return
+		}
+	}
+}
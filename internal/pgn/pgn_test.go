@@ -0,0 +1,248 @@
+package pgn
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/schafer14/chess-serve/internal/chess"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// line makes a line of moves without annotations.
+func line(sans ...string) []Move {
+	moves := make([]Move, len(sans))
+	for i, san := range sans {
+		moves[i] = Move{SAN: san}
+	}
+
+	return moves
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		pgn   string
+		games []Game
+	}{
+		{
+			"tag pairs with escaped quotes",
+			`[Event "The \"Immortal\" Game"]` + "\n" + `[Site "C:\\games"]` + "\n\n1. e4 *\n",
+			[]Game{{Tags: map[string]string{"Event": `The "Immortal" Game`, "Site": `C:\games`}, Moves: line("e4"), Result: "*"}},
+		},
+		{
+			"comments",
+			"{Played online} 1. e4 {Best by test} e5 ; the open game\n2. Nf3 *",
+			[]Game{{
+				Tags:     map[string]string{},
+				Comments: []string{"Played online"},
+				Moves: []Move{
+					{SAN: "e4", Comments: []string{"Best by test"}},
+					{SAN: "e5", Comments: []string{"the open game"}},
+					{SAN: "Nf3"},
+				},
+				Result: "*",
+			}},
+		},
+		{
+			"escaped lines",
+			"% written by some program\n1. e4 e5\n%2. d4\n2. Nf3 *",
+			[]Game{{Tags: map[string]string{}, Moves: line("e4", "e5", "Nf3"), Result: "*"}},
+		},
+		{
+			"annotations",
+			"1. e4! e5?! 2. Nf3 $1 $14 Nc6!? *",
+			[]Game{{
+				Tags: map[string]string{},
+				Moves: []Move{
+					{SAN: "e4", NAGs: []int{1}},
+					{SAN: "e5", NAGs: []int{6}},
+					{SAN: "Nf3", NAGs: []int{1, 14}},
+					{SAN: "Nc6", NAGs: []int{5}},
+				},
+				Result: "*",
+			}},
+		},
+		{
+			"nested variations",
+			"1. e4 (1. d4 d5 (1... Nf6 2. c4)) (1. c4) e5 *",
+			[]Game{{
+				Tags: map[string]string{},
+				Moves: []Move{
+					{SAN: "e4", Variations: [][]Move{
+						{{SAN: "d4"}, {SAN: "d5", Variations: [][]Move{line("Nf6", "c4")}}},
+						line("c4"),
+					}},
+					{SAN: "e5"},
+				},
+				Result: "*",
+			}},
+		},
+		{
+			"black to move first",
+			"1... e5 2. Nf3 Nc6 *",
+			[]Game{{Tags: map[string]string{}, Moves: line("e5", "Nf3", "Nc6"), Result: "*"}},
+		},
+		{
+			"move numbers without spaces",
+			"1.e4 e5 2.Nf3 *",
+			[]Game{{Tags: map[string]string{}, Moves: line("e4", "e5", "Nf3"), Result: "*"}},
+		},
+		{
+			"draw",
+			"1. e4 e5 1/2-1/2",
+			[]Game{{Tags: map[string]string{}, Moves: line("e4", "e5"), Result: "1/2-1/2"}},
+		},
+		{
+			"result only in the tags",
+			`[Result "0-1"]` + "\n\n1. f3 e5 2. g4 Qh4#",
+			[]Game{{Tags: map[string]string{"Result": "0-1"}, Moves: line("f3", "e5", "g4", "Qh4#"), Result: "0-1"}},
+		},
+		{
+			"several games",
+			`[White "a"]` + "\n\n1. e4 1-0\n\n" + `[White "b"]` + "\n\n1. d4 *\n",
+			[]Game{
+				{Tags: map[string]string{"White": "a"}, Moves: line("e4"), Result: "1-0"},
+				{Tags: map[string]string{"White": "b"}, Moves: line("d4"), Result: "*"},
+			},
+		},
+		{
+			"no games",
+			"\n",
+			nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			games, err := Parse(strings.NewReader(tt.pgn))
+			if err != nil {
+				t.Fatalf("parsing: %v", err)
+			}
+			if !reflect.DeepEqual(games, tt.games) {
+				t.Errorf("got %+v, want %+v", games, tt.games)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		pgn  string
+		err  string
+	}{
+		{"unterminated variation", "1. e4 (1. d4 d5 *", "line 1: unterminated variation"},
+		{"unexpected )", "1. e4 e5)\n*", "line 1: unexpected )"},
+		{"suffix before any move", "\n!? 1. e4 *", "line 2: annotation before any move"},
+		{"glyph before any move", "$1 1. e4 *", "line 1: annotation before any move"},
+		{"variation before any move", "(1. d4) 1. e4 *", "line 1: variation before any move"},
+		{"tag without a value", "[Event]\n1. e4 *", "line 1: expected value for tag Event"},
+		{"error in a later game", "1. e4 *\n\n1. e4 ) *", "parsing game 2: line 3: unexpected )"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(tt.pgn))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got error %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestParseWrittenGame(t *testing.T) {
+	now := time.Now()
+	g, err := chess.NewGame(primitive.NewObjectID(), now, chess.Player{Id: "white", Name: `Alice "the rook"`}, chess.Options{TimeControl: &chess.TimeControl{Base: 300, Increment: 2}})
+	if err != nil {
+		t.Fatalf("creating game: %v", err)
+	}
+	if err := g.Join(chess.Player{Id: "black", Name: "Bob"}, now); err != nil {
+		t.Fatalf("joining game: %v", err)
+	}
+
+	moves := strings.Fields("e2e4 e7e5 g1f3 b8c6 f1b5 a7a6 b5a4 g8f6 e1g1 f8e7 f1e1 b7b5 a4b3 d7d6 c2c3 e8g8 h2h3 c6b8 d2d4 b8d7")
+	for i, m := range moves {
+		player := "white"
+		if i%2 == 1 {
+			player = "black"
+		}
+		now = now.Add(time.Second)
+		if err := g.Move(m, player, now); err != nil {
+			t.Fatalf("playing %v: %v", m, err)
+		}
+	}
+
+	var b strings.Builder
+	if err := g.WritePGN(&b); err != nil {
+		t.Fatalf("writing pgn: %v", err)
+	}
+
+	games, err := Parse(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatalf("parsing\n%v\n%v", b.String(), err)
+	}
+	if len(games) != 1 {
+		t.Fatalf("got %d games, want 1", len(games))
+	}
+	parsed := games[0]
+
+	if parsed.Tags["White"] != `Alice "the rook"` || parsed.Tags["Black"] != "Bob" || parsed.Result != "*" {
+		t.Errorf("got tags %v with result %v", parsed.Tags, parsed.Result)
+	}
+
+	var sans []string
+	for _, m := range parsed.Moves {
+		sans = append(sans, m.SAN)
+	}
+	if got, want := strings.Join(sans, " "), strings.Join(g.SAN(), " "); got != want {
+		t.Errorf("got moves %v, want %v", got, want)
+	}
+
+	played, errs := parsed.Play()
+	if len(errs) > 0 {
+		t.Fatalf("playing parsed game: %v", errs)
+	}
+	if got, want := strings.Join(played, " "), strings.Join(moves, " "); got != want {
+		t.Errorf("played %v, want %v", got, want)
+	}
+}
+
+func TestPlay(t *testing.T) {
+	tests := []struct {
+		name  string
+		pgn   string
+		moves string
+		errs  []MoveError
+	}{
+		{"legal moves", "1. e4 e5 2. Nf3 *", "e2e4 e7e5 g1f3", nil},
+		{"illegal move", "1. e4 e5 2. Ke3 Nc6 *", "e2e4 e7e5", []MoveError{{Ply: 3, Move: "Ke3"}}},
+		{"illegal move in a variation", "1. e4 (1. d4 Ke7) e5 *", "e2e4 e7e5", []MoveError{{Ply: 2, Move: "Ke7", Variation: true}}},
+		{"position from the tags", `[FEN "4k3/8/8/8/8/8/4P3/4K3 b - - 0 1"]` + "\n\n1... Kd7 2. e4 *", "e8d7 e2e4", nil},
+		{"another variant", `[Variant "Crazyhouse"]` + "\n\n1. e4 *", "", []MoveError{{}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			games, err := Parse(strings.NewReader(tt.pgn))
+			if err != nil {
+				t.Fatalf("parsing: %v", err)
+			}
+
+			moves, errs := games[0].Play()
+			if got := strings.Join(moves, " "); got != tt.moves {
+				t.Errorf("played %v, want %v", got, tt.moves)
+			}
+			if len(errs) != len(tt.errs) {
+				t.Fatalf("got errors %+v, want %+v", errs, tt.errs)
+			}
+			for i, e := range errs {
+				want := tt.errs[i]
+				if e.Ply != want.Ply || e.Move != want.Move || e.Variation != want.Variation || e.Error == "" {
+					t.Errorf("got error %+v, want %+v", e, want)
+				}
+			}
+		})
+	}
+}
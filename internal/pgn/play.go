@@ -0,0 +1,66 @@
+package pgn
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/schafer14/MtM/board"
+	"github.com/schafer14/chess-serve/internal/chess"
+)
+
+// MoveError describes a move of a game that could not be played. Errors that
+// stop the whole game from being played have ply 0.
+type MoveError struct {
+	Ply       int    `json:"ply"`
+	Move      string `json:"move"`
+	Variation bool   `json:"variation"`
+	Error     string `json:"error"`
+}
+
+// Play checks every move of the game, including the moves of variations,
+// against the rules of chess. It returns the main line up to the first
+// illegal move in the src-dest notation used by the chess package along with
+// every move that could not be played. Games with a FEN tag are played from
+// that position.
+func (g Game) Play() ([]string, []MoveError) {
+	if variant, ok := g.Tags["Variant"]; ok && !strings.EqualFold(variant, "standard") {
+		return nil, []MoveError{{Error: fmt.Sprintf("the %s variant is not supported", variant)}}
+	}
+
+	b := board.New()
+	if fen, ok := g.Tags["FEN"]; ok {
+		var err error
+		if b, err = chess.ParseFen(fen); err != nil {
+			return nil, []MoveError{{Error: err.Error()}}
+		}
+	}
+
+	var errs []MoveError
+	moves := play(b, 1, g.Moves, false, &errs)
+
+	return moves, errs
+}
+
+// play plays a line of moves from a position. Variations are played from the
+// position before the move they replace. Playing a line stops at the first
+// illegal move.
+func play(b board.Board, ply int, line []Move, variation bool, errs *[]MoveError) []string {
+	var moves []string
+
+	for i, m := range line {
+		for _, v := range m.Variations {
+			play(b, ply+i, v, true, errs)
+		}
+
+		mv, err := chess.ParseSAN(b, m.SAN)
+		if err != nil {
+			*errs = append(*errs, MoveError{Ply: ply + i, Move: m.SAN, Variation: variation, Error: err.Error()})
+			return moves
+		}
+
+		moves = append(moves, mv.String())
+		b.Move(mv)
+	}
+
+	return moves
+}
@@ -0,0 +1,109 @@
+// Package sweeper ends games in the background when a player runs out of
+// time or abandons a game without anyone making a request for it.
+package sweeper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/pkg/errors"
+	"github.com/schafer14/chess-serve/internal/chess"
+)
+
+// Sweeper periodically checks the games in progress and ends the ones that
+// have expired. Several sweepers can run against the same store, only the
+// one that manages to save a finished game publishes its result.
+type Sweeper struct {
+	store    chess.GameStore
+	nc       *nats.Conn
+	interval time.Duration
+	limits   chess.Abandonment
+}
+
+// New creates a sweeper that checks games every interval.
+func New(store chess.GameStore, nc *nats.Conn, interval time.Duration, limits chess.Abandonment) *Sweeper {
+	return &Sweeper{
+		store:    store,
+		nc:       nc,
+		interval: interval,
+		limits:   limits,
+	}
+}
+
+// Run sweeps games until the context is cancelled.
+func (s *Sweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := s.sweep(ctx, now); err != nil {
+				log.Printf("sweeper : %v", err)
+			}
+		}
+	}
+}
+
+// sweep ends every game that has expired at the given time. Only the games
+// that are due are loaded, which are the games where a clock has run out or
+// nobody has moved for as long as the shortest abandonment limit.
+func (s *Sweeper) sweep(ctx context.Context, now time.Time) error {
+	f := chess.GameFilter{Status: []int{chess.StatusInProgress}, Due: now, Idle: s.idle()}
+	err := s.store.List(ctx, f, func(game chess.Game) error {
+		seen := len(game.Events(0))
+		if !game.Expire(now, s.limits) {
+			return nil
+		}
+
+		// A game that was saved since it was loaded has been played on or
+		// ended by someone else.
+		err := s.store.Update(ctx, game)
+		if errors.Cause(err) == chess.ErrConflict {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, e := range game.Events(seen) {
+			s.publish(game.ID(), "event", e)
+		}
+		s.publish(game.ID(), "end", game.Outcome())
+		return nil
+	})
+
+	return errors.Wrap(err, "sweeping games")
+}
+
+// idle is the shortest time a game can go without a move before a player is
+// treated as having abandoned it. Without abandonment limits games only
+// expire on time.
+func (s *Sweeper) idle() time.Duration {
+	idle := s.limits.FirstMove
+	if d := s.limits.Disconnect; d > 0 && (idle <= 0 || d < idle) {
+		idle = d
+	}
+	if idle < 0 {
+		return 0
+	}
+
+	return idle
+}
+
+// publish sends a json encoded event to everyone following a game.
+func (s *Sweeper) publish(gameId string, event string, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("sweeper : encoding %v event: %v", event, err)
+		return
+	}
+
+	s.nc.Publish(fmt.Sprintf("game.%v.%v", gameId, event), data)
+}
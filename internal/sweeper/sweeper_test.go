@@ -0,0 +1,96 @@
+package sweeper
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/schafer14/chess-serve/internal/chess"
+)
+
+var (
+	white = chess.Player{Id: "white"}
+	black = chess.Player{Id: "black"}
+)
+
+// start stores a game between white and black that started at a time with
+// the moves played at that time.
+func start(t *testing.T, store chess.GameStore, tc *chess.TimeControl, started time.Time, moves ...string) string {
+	t.Helper()
+	ctx := context.Background()
+
+	g, err := chess.StartGame(ctx, store, white, black, chess.Options{TimeControl: tc}, started)
+	if err != nil {
+		t.Fatalf("starting game: %v", err)
+	}
+	for i, m := range moves {
+		player := white.Id
+		if i%2 == 1 {
+			player = black.Id
+		}
+		if err := g.Move(m, player, started); err != nil {
+			t.Fatalf("playing %v: %v", m, err)
+		}
+	}
+	if err := store.Update(ctx, g); err != nil {
+		t.Fatalf("saving game: %v", err)
+	}
+
+	return g.ID()
+}
+
+func TestSweep(t *testing.T) {
+	ctx := context.Background()
+	started := time.Now()
+	store := chess.NewMemoryStore()
+
+	timed := start(t, store, &chess.TimeControl{Base: 60}, started, "e4", "e5")
+	unplayed := start(t, store, nil, started)
+	played := start(t, store, nil, started, "e4", "e5")
+	if err := store.MarkSeen(ctx, played, white.Id, started); err != nil {
+		t.Fatalf("marking white as seen: %v", err)
+	}
+
+	playing := chess.Outcome{Result: chess.ResultNone}
+	s := New(store, nil, time.Second, chess.Abandonment{FirstMove: 30 * time.Second, Disconnect: 2 * time.Minute})
+
+	tests := []struct {
+		name     string
+		after    time.Duration
+		outcomes map[string]chess.Outcome
+	}{
+		{"nothing has expired", 29 * time.Second, map[string]chess.Outcome{
+			timed:    chess.Outcome{Result: chess.ResultNone},
+			unplayed: playing,
+			played:   chess.Outcome{Result: chess.ResultNone},
+		}},
+		{"nobody made the first move", 31 * time.Second, map[string]chess.Outcome{
+			timed:    chess.Outcome{Result: chess.ResultNone},
+			unplayed: {Result: chess.ResultNone, Termination: chess.TerminationAbandoned},
+			played:   chess.Outcome{Result: chess.ResultNone},
+		}},
+		{"white ran out of time", 61 * time.Second, map[string]chess.Outcome{
+			timed:  chess.Outcome{Result: chess.ResultBlackWins, Termination: chess.TerminationTimeout},
+			played: playing,
+		}},
+		{"white left the game", 3 * time.Minute, map[string]chess.Outcome{
+			played: {Result: chess.ResultBlackWins, Termination: chess.TerminationAbandoned},
+		}},
+	}
+
+	for _, tt := range tests {
+		if err := s.sweep(ctx, started.Add(tt.after)); err != nil {
+			t.Fatalf("%v: sweeping: %v", tt.name, err)
+		}
+
+		for id, want := range tt.outcomes {
+			g, err := store.Get(ctx, id)
+			if err != nil {
+				t.Fatalf("%v: getting game: %v", tt.name, err)
+			}
+			if g.Outcome() != want {
+				t.Errorf("%v: got %v, want %v", tt.name, g.Outcome(), want)
+			}
+		}
+	}
+}